@@ -0,0 +1,108 @@
+package multicluster
+
+import (
+	"container/list"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/coredns/multicluster/object"
+)
+
+const (
+	// affinitySweepInterval is the minimum interval between removals of expired affinities.
+	affinitySweepInterval = time.Minute
+	// defaultMaxAffinities is the maximum number of affinities kept. Clients choose the address in
+	// the EDNS0 Client Subnet option, so the table must not grow with them.
+	defaultMaxAffinities = 10000
+)
+
+type affinityKey struct {
+	client  string
+	service string
+}
+
+type affinity struct {
+	key     affinityKey
+	cluster string
+	expires time.Time
+}
+
+// affinityTable pins clients of headless services with ClientIP session affinity to a cluster. When
+// full, the affinity answered the longest ago is evicted.
+type affinityTable struct {
+	sync.Mutex
+	entries   map[affinityKey]*list.Element
+	lru       *list.List // of *affinity, most recently answered first
+	max       int
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+func newAffinityTable() *affinityTable {
+	return &affinityTable{entries: map[affinityKey]*list.Element{}, lru: list.New(), max: defaultMaxAffinities, now: time.Now}
+}
+
+// stick keeps the candidates of the cluster client is pinned to for svc. If client isn't pinned yet,
+// its pin expired or the cluster has no candidates left, a cluster is picked by a hash of the client
+// address and pinned. Every answer extends the pin by the affinity timeout.
+func (t *affinityTable) stick(svc *object.ServiceImport, client net.IP, candidates []candidate) []candidate {
+	clusters := candidateClusters(candidates)
+	if len(clusters) == 0 {
+		return candidates
+	}
+
+	key := affinityKey{client: client.String(), service: svc.Index}
+	now := t.now()
+
+	t.Lock()
+	defer t.Unlock()
+
+	t.sweep(now)
+
+	cluster := ""
+	e, ok := t.entries[key]
+	if ok && now.Before(e.Value.(*affinity).expires) {
+		for _, c := range clusters {
+			if c == e.Value.(*affinity).cluster {
+				cluster = c
+				break
+			}
+		}
+	}
+	if cluster == "" {
+		cluster = pickCluster(clusters, func(string) int { return 1 }, client, svc.Index)
+	}
+	a := &affinity{key: key, cluster: cluster, expires: now.Add(time.Duration(svc.SessionAffinityTimeout) * time.Second)}
+	if ok {
+		e.Value = a
+		t.lru.MoveToFront(e)
+	} else {
+		t.entries[key] = t.lru.PushFront(a)
+		if t.lru.Len() > t.max {
+			t.remove(t.lru.Back())
+		}
+	}
+
+	return clusterCandidates(candidates, cluster)
+}
+
+// remove removes the affinity of e. It must be called with the lock held.
+func (t *affinityTable) remove(e *list.Element) {
+	delete(t.entries, e.Value.(*affinity).key)
+	t.lru.Remove(e)
+}
+
+// sweep removes expired affinities, at most once per affinitySweepInterval. It must be called with the lock held.
+func (t *affinityTable) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < affinitySweepInterval {
+		return
+	}
+	t.lastSweep = now
+	for _, e := range t.entries {
+		if !now.Before(e.Value.(*affinity).expires) {
+			t.remove(e)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package multicluster
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+	"github.com/coredns/multicluster/object"
+	"github.com/miekg/dns"
+	api "k8s.io/api/core/v1"
+)
+
+func TestAffinityStick(t *testing.T) {
+	now := time.Now()
+	table := newAffinityTable()
+	table.now = func() time.Time { return now }
+
+	svc := &object.ServiceImport{
+		Index:                  "hdls1.testns",
+		SessionAffinity:        api.ServiceAffinityClientIP,
+		SessionAffinityTimeout: 60,
+	}
+	client := net.ParseIP("10.1.2.3")
+	key := affinityKey{client: client.String(), service: svc.Index}
+
+	selected := table.stick(svc, client, weightCandidates())
+	if len(selected) == 0 {
+		t.Fatal("Expected candidates to be selected")
+	}
+	pinned := selected[0].cluster
+	for _, c := range selected {
+		if c.cluster != pinned {
+			t.Fatalf("Expected endpoints of a single cluster, got %v", selected)
+		}
+	}
+
+	// the pin holds even if the hash would pick another cluster
+	*table.entries[key].Value.(*affinity) = affinity{key: key, cluster: "c", expires: now.Add(time.Minute)}
+	now = now.Add(30 * time.Second)
+	if selected := table.stick(svc, client, weightCandidates()); len(selected) != 1 || selected[0].cluster != "c" {
+		t.Fatalf("Expected pinned cluster c, got %v", selected)
+	}
+
+	// every answer extends the pin
+	now = now.Add(50 * time.Second)
+	if selected := table.stick(svc, client, weightCandidates()); len(selected) != 1 || selected[0].cluster != "c" {
+		t.Fatalf("Expected pinned cluster c after extension, got %v", selected)
+	}
+
+	// a pinned cluster without endpoints is replaced
+	withoutC := []candidate{
+		{cluster: "a", service: msg.Service{Host: "10.0.0.1"}},
+		{cluster: "b", service: msg.Service{Host: "10.0.1.1"}},
+	}
+	selected = table.stick(svc, client, withoutC)
+	if len(selected) != 1 || selected[0].cluster == "c" {
+		t.Fatalf("Expected a new cluster to be pinned, got %v", selected)
+	}
+	if a := table.entries[key].Value.(*affinity); a.cluster != selected[0].cluster {
+		t.Errorf("Expected %s to be pinned, got %s", selected[0].cluster, a.cluster)
+	}
+
+	// expired pins are swept
+	now = now.Add(2 * time.Hour)
+	table.stick(svc, net.ParseIP("10.9.9.9"), withoutC)
+	if _, ok := table.entries[key]; ok {
+		t.Error("Expected the expired pin to be removed")
+	}
+}
+
+func TestAffinityMax(t *testing.T) {
+	table := newAffinityTable()
+	table.max = 2
+	svc := &object.ServiceImport{
+		Index:                  "hdls1.testns",
+		SessionAffinity:        api.ServiceAffinityClientIP,
+		SessionAffinityTimeout: api.DefaultClientIPServiceAffinitySeconds,
+	}
+
+	table.stick(svc, net.ParseIP("10.0.0.1"), weightCandidates())
+	table.stick(svc, net.ParseIP("10.0.0.2"), weightCandidates())
+	// answering 10.0.0.1 again makes 10.0.0.2 the least recently answered
+	table.stick(svc, net.ParseIP("10.0.0.1"), weightCandidates())
+	table.stick(svc, net.ParseIP("10.0.0.3"), weightCandidates())
+
+	if len(table.entries) != 2 || table.lru.Len() != 2 {
+		t.Fatalf("Expected 2 affinities, got %d", len(table.entries))
+	}
+	for _, client := range []string{"10.0.0.1", "10.0.0.3"} {
+		if _, ok := table.entries[affinityKey{client: client, service: svc.Index}]; !ok {
+			t.Errorf("Expected %s to be pinned", client)
+		}
+	}
+	if _, ok := table.entries[affinityKey{client: "10.0.0.2", service: svc.Index}]; ok {
+		t.Error("Expected the least recently answered client to be evicted")
+	}
+}
+
+func TestSelectCandidatesAffinity(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	svc := &object.ServiceImport{Index: "hdls1.testns"}
+	state := request.Request{W: &test.ResponseWriter{}, Req: new(dns.Msg)}
+
+	// without affinity all candidates are kept
+	if selected := m.selectCandidates(svc, recordRequest{}, state, weightCandidates()); len(selected) != len(weightCandidates()) {
+		t.Errorf("Expected all candidates without affinity, got %v", selected)
+	}
+
+	svc.SessionAffinity = api.ServiceAffinityClientIP
+	svc.SessionAffinityTimeout = api.DefaultClientIPServiceAffinitySeconds
+	selected := m.selectCandidates(svc, recordRequest{}, state, weightCandidates())
+	for _, c := range selected {
+		if c.cluster != selected[0].cluster {
+			t.Fatalf("Expected endpoints of a single cluster, got %v", selected)
+		}
+	}
+	if len(m.affinity.entries) != 1 {
+		t.Errorf("Expected the client to be pinned, got %v", m.affinity.entries)
+	}
+
+	// explicit endpoint queries are answered as is
+	if selected := m.selectCandidates(svc, recordRequest{endpoint: "172-0-0-2"}, state, weightCandidates()); len(selected) != len(weightCandidates()) {
+		t.Errorf("Expected all candidates for endpoint queries, got %v", selected)
+	}
+}
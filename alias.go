@@ -0,0 +1,80 @@
+package multicluster
+
+// clusterAliases maps cluster ids to friendlier names used in DNS labels.
+type clusterAliases struct {
+	byID    map[string]string
+	byAlias map[string]string
+}
+
+func newClusterAliases() *clusterAliases {
+	return &clusterAliases{byID: map[string]string{}, byAlias: map[string]string{}}
+}
+
+// add sets the alias of cluster id.
+func (a *clusterAliases) add(id, alias string) {
+	a.byID[id] = alias
+	a.byAlias[alias] = id
+}
+
+// label returns the label the cluster id is served under: its alias if it has one, the id otherwise.
+func (a *clusterAliases) label(id string) string {
+	if alias, ok := a.byID[id]; ok {
+		return alias
+	}
+	return id
+}
+
+// match returns true if label is the alias or the id of cluster id.
+func (a *clusterAliases) match(label, id string) bool {
+	if match(label, id) {
+		return true
+	}
+	alias, ok := a.byID[id]
+	return ok && match(label, alias)
+}
+
+// id returns the cluster id name stands for: the id of the cluster if name is an alias, name otherwise.
+func (a *clusterAliases) id(name string) string {
+	if id, ok := a.byAlias[name]; ok {
+		return id
+	}
+	return name
+}
+
+// resolveSet returns set with its aliases replaced by cluster ids.
+func (a *clusterAliases) resolveSet(set map[string]struct{}) map[string]struct{} {
+	if set == nil {
+		return nil
+	}
+	resolved := make(map[string]struct{}, len(set))
+	for name := range set {
+		resolved[a.id(name)] = struct{}{}
+	}
+	return resolved
+}
+
+// resolveAliases replaces the aliases used by drain, weight, subnet and allowed_clusters with cluster
+// ids. It's called once the whole stanza is parsed, as cluster_alias may follow these directives.
+func (m *MultiCluster) resolveAliases() {
+	m.drain.static = m.aliases.resolveSet(m.drain.static)
+	if m.weights != nil {
+		weights := make(map[string]int, len(m.weights))
+		for name, w := range m.weights {
+			weights[m.aliases.id(name)] = w
+		}
+		m.weights = weights
+	}
+	for i := range m.subnets {
+		m.subnets[i].clusters = m.aliases.resolveSet(m.subnets[i].clusters)
+	}
+	m.opts.sliceFilter.clusters = m.aliases.resolveSet(m.opts.sliceFilter.clusters)
+}
+
+// labelConflict returns a copy of c with the clusters replaced by their labels.
+func (a *clusterAliases) labelConflict(c *serviceConflict) *serviceConflict {
+	labeled := &serviceConflict{Service: c.Service, Winner: a.label(c.Winner), Losers: make(map[string]string, len(c.Losers))}
+	for cluster, reason := range c.Losers {
+		labeled.Losers[a.label(cluster)] = reason
+	}
+	return labeled
+}
@@ -0,0 +1,96 @@
+package multicluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+)
+
+var aliasTestCases = []test.Case{
+	// endpoints are resolvable by alias
+	{
+		Qname: "172-0-0-2.East.hdls1.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("172-0-0-2.East.hdls1.testns.svc.cluster.local.	5	IN	A	172.0.0.2"),
+		},
+	},
+	// and by id
+	{
+		Qname: "172-0-0-2.clusterid.hdls1.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("172-0-0-2.clusterid.hdls1.testns.svc.cluster.local.	5	IN	A	172.0.0.2"),
+		},
+	},
+	// SRV targets use the alias
+	{
+		Qname: "_http._tcp.hdlsstale.testns.svc.cluster.local.", Qtype: dns.TypeSRV,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.SRV("_http._tcp.hdlsstale.testns.svc.cluster.local.	5	IN	SRV	0 100 80 172-0-0-30.east.hdlsstale.testns.svc.cluster.local."),
+		},
+		Extra: []dns.RR{
+			test.A("172-0-0-30.east.hdlsstale.testns.svc.cluster.local.	5	IN	A	172.0.0.30"),
+		},
+	},
+	{
+		Qname: "172-0-0-2.west.hdls1.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+}
+
+func TestClusterAliasServeDNS(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	m.aliases.add("clusterid", "east")
+	ctx := context.TODO()
+
+	for i, tc := range aliasTestCases {
+		r := tc.Msg()
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+
+		if _, err := m.ServeDNS(ctx, w, r); err != nil {
+			t.Errorf("Test %d expected no error, got %v", i, err)
+			continue
+		}
+		if err := test.SortAndCheck(w.Msg, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+	}
+}
+
+func TestResolveAliases(t *testing.T) {
+	c := caddy.NewTestController("dns", `multicluster clusterset.local {
+    drain east
+    weight east 3
+    weight c2 1
+    subnet 10.0.0.0/8 east c2
+    allowed_clusters east c2
+    cluster_alias c1 east
+}`)
+	m, err := ParseStanza(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := m.drain.static["c1"]; !ok || len(m.drain.static) != 1 {
+		t.Errorf("Expected c1 to be drained, got %v", m.drain.static)
+	}
+	if m.weights["c1"] != 3 || m.weights["c2"] != 1 || len(m.weights) != 2 {
+		t.Errorf("Expected weights for c1 and c2, got %v", m.weights)
+	}
+	if _, ok := m.subnets[0].clusters["c1"]; !ok || len(m.subnets[0].clusters) != 2 {
+		t.Errorf("Expected the subnet to prefer c1 and c2, got %v", m.subnets[0].clusters)
+	}
+	if _, ok := m.opts.sliceFilter.clusters["c1"]; !ok || len(m.opts.sliceFilter.clusters) != 2 {
+		t.Errorf("Expected c1 and c2 to be allowed, got %v", m.opts.sliceFilter.clusters)
+	}
+}
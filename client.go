@@ -0,0 +1,19 @@
+package multicluster
+
+import (
+	"net"
+
+	"github.com/coredns/coredns/request"
+)
+
+// clientIP returns the address of the client, or nil if it isn't known. The address of the
+// EDNS0 Client Subnet option takes precedence over the source address of the query.
+func clientIP(state request.Request) net.IP {
+	if ecs := clientSubnet(state.Req); ecs != nil && ecs.Address != nil && !ecs.Address.IsUnspecified() {
+		return ecs.Address
+	}
+	if state.W == nil {
+		return nil
+	}
+	return net.ParseIP(state.IP())
+}
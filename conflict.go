@@ -0,0 +1,220 @@
+package multicluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const (
+	conflictReasonType     = "type"
+	conflictReasonPort     = "port"
+	conflictReasonProtocol = "protocol"
+)
+
+// exportSource describes how a single cluster exports a service.
+type exportSource struct {
+	cluster string
+	// created is the time the cluster started exporting the service.
+	created time.Time
+	// typ is the type of the exported service, or empty if it can't be told.
+	typ   mcs.ServiceImportType
+	ports []mcs.ServicePort
+	// winning is set for the current winner, which keeps winning over older exports.
+	winning bool
+}
+
+// serviceConflict describes the conflicting exports of a service.
+type serviceConflict struct {
+	// Service is the index of the service.
+	Service string `json:"service"`
+	// Winner is the cluster whose export is served.
+	Winner string `json:"winner"`
+	// Losers maps each cluster whose export conflicts with the winner to the reason.
+	Losers map[string]string `json:"losers"`
+}
+
+func (c *serviceConflict) String() string {
+	losers := make([]string, 0, len(c.Losers))
+	for cluster, reason := range c.Losers {
+		losers = append(losers, cluster+" ("+reason+")")
+	}
+	sort.Strings(losers)
+	return fmt.Sprintf("service %s: export of cluster %s wins over %s", c.Service, c.Winner, strings.Join(losers, ", "))
+}
+
+// resolveConflict picks the winning export of a service, as per the MCS spec the oldest export
+// wins, ties are broken by cluster id. An export marked as winning keeps winning. Every export with a different type, or a port with the
+// same name but a different protocol (or number, if comparePorts is set) loses. It returns nil if
+// there are no conflicts.
+func resolveConflict(idx string, sources []exportSource, comparePorts bool) *serviceConflict {
+	if len(sources) < 2 {
+		return nil
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].winning != sources[j].winning {
+			return sources[i].winning
+		}
+		if !sources[i].created.Equal(sources[j].created) {
+			return sources[i].created.Before(sources[j].created)
+		}
+		return sources[i].cluster < sources[j].cluster
+	})
+	winner := sources[0]
+	winnerPorts := make(map[string]mcs.ServicePort, len(winner.ports))
+	for _, p := range winner.ports {
+		winnerPorts[p.Name] = p
+	}
+
+	var c *serviceConflict
+	for _, s := range sources[1:] {
+		reason := ""
+		if s.typ != "" && winner.typ != "" && s.typ != winner.typ {
+			reason = conflictReasonType
+		}
+		for _, p := range s.ports {
+			if reason != "" {
+				break
+			}
+			wp, ok := winnerPorts[p.Name]
+			if !ok {
+				continue
+			}
+			if !strings.EqualFold(string(wp.Protocol), string(p.Protocol)) {
+				reason = conflictReasonProtocol
+			} else if comparePorts && wp.Port != p.Port {
+				reason = conflictReasonPort
+			}
+		}
+		if reason == "" {
+			continue
+		}
+		if c == nil {
+			c = &serviceConflict{Service: idx, Winner: winner.cluster, Losers: map[string]string{}}
+		}
+		c.Losers[s.cluster] = reason
+	}
+	return c
+}
+
+// conflictTracker keeps the conflicts currently detected, logging and counting them as they appear.
+type conflictTracker struct {
+	sync.RWMutex
+	conflicts map[string]*serviceConflict
+
+	// onConflict, if set, is called for every new or changed conflict.
+	onConflict func(*serviceConflict)
+}
+
+func newConflictTracker() *conflictTracker {
+	return &conflictTracker{conflicts: map[string]*serviceConflict{}}
+}
+
+// update records the conflict, or its absence if c is nil, for the service idx.
+func (t *conflictTracker) update(idx string, c *serviceConflict) {
+	t.Lock()
+	old := t.conflicts[idx]
+	if c == nil {
+		delete(t.conflicts, idx)
+	} else {
+		t.conflicts[idx] = c
+	}
+	t.Unlock()
+
+	if c == nil {
+		if old != nil {
+			log.Infof("Conflict resolved for service %s", idx)
+		}
+		return
+	}
+	if old != nil && conflictsEqual(old, c) {
+		return
+	}
+	log.Warningf("Conflicting exports for %s", c)
+	for _, reason := range c.Losers {
+		conflictCount.WithLabelValues(reason).Inc()
+	}
+	if t.onConflict != nil {
+		t.onConflict(c)
+	}
+}
+
+// lost returns true if the export of cluster for the service idx lost a conflict.
+func (t *conflictTracker) lost(idx, cluster string) bool {
+	t.RLock()
+	defer t.RUnlock()
+	c, ok := t.conflicts[idx]
+	if !ok {
+		return false
+	}
+	_, ok = c.Losers[cluster]
+	return ok
+}
+
+// winner returns the cluster winning the current conflict of the service idx, if any.
+func (t *conflictTracker) winner(idx string) string {
+	t.RLock()
+	defer t.RUnlock()
+	if c, ok := t.conflicts[idx]; ok {
+		return c.Winner
+	}
+	return ""
+}
+
+// list returns all current conflicts.
+func (t *conflictTracker) list() []*serviceConflict {
+	t.RLock()
+	defer t.RUnlock()
+	cs := make([]*serviceConflict, 0, len(t.conflicts))
+	for _, c := range t.conflicts {
+		cs = append(cs, c)
+	}
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Service < cs[j].Service })
+	return cs
+}
+
+func conflictsEqual(a, b *serviceConflict) bool {
+	if a.Winner != b.Winner || len(a.Losers) != len(b.Losers) {
+		return false
+	}
+	for cluster, reason := range a.Losers {
+		if b.Losers[cluster] != reason {
+			return false
+		}
+	}
+	return true
+}
+
+// emitConflictEvent creates a Warning event for the conflict on the object ref.
+func emitConflictEvent(client kubernetes.Interface, ref api.ObjectReference, c *serviceConflict) {
+	now := meta.Now()
+	event := &api.Event{
+		ObjectMeta: meta.ObjectMeta{
+			GenerateName: ref.Name + ".",
+			Namespace:    ref.Namespace,
+		},
+		InvolvedObject: ref,
+		Reason:         "ExportConflict",
+		Message:        c.String(),
+		Type:           api.EventTypeWarning,
+		Source:         api.EventSource{Component: "coredns-" + pluginName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := client.CoreV1().Events(ref.Namespace).Create(ctx, event, meta.CreateOptions{}); err != nil {
+			log.Warningf("Failed to create conflict event for %s/%s: %v", ref.Namespace, ref.Name, err)
+		}
+	}()
+}
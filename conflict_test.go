@@ -0,0 +1,161 @@
+package multicluster
+
+import (
+	"testing"
+	"time"
+
+	k8sObject "github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/multicluster/object"
+	"k8s.io/client-go/tools/cache"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+func TestResolveConflict(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	t1 := time.Unix(2000, 0)
+
+	tests := []struct {
+		sources      []exportSource
+		comparePorts bool
+		winner       string
+		losers       map[string]string
+	}{
+		// single export
+		{
+			sources: []exportSource{{cluster: "a", created: t0, typ: mcs.Headless}},
+		},
+		// compatible exports
+		{
+			sources: []exportSource{
+				{cluster: "a", created: t0, typ: mcs.ClusterSetIP, ports: []mcs.ServicePort{{Name: "http", Protocol: "TCP", Port: 80}}},
+				{cluster: "b", created: t1, typ: mcs.ClusterSetIP, ports: []mcs.ServicePort{{Name: "http", Protocol: "TCP", Port: 80}}},
+			},
+			comparePorts: true,
+		},
+		// type conflict, oldest wins
+		{
+			sources: []exportSource{
+				{cluster: "a", created: t1, typ: mcs.ClusterSetIP},
+				{cluster: "b", created: t0, typ: mcs.Headless},
+			},
+			winner: "b",
+			losers: map[string]string{"a": conflictReasonType},
+		},
+		// protocol conflict, ties broken by cluster id
+		{
+			sources: []exportSource{
+				{cluster: "b", created: t0, ports: []mcs.ServicePort{{Name: "dns", Protocol: "UDP", Port: 53}}},
+				{cluster: "a", created: t0, ports: []mcs.ServicePort{{Name: "dns", Protocol: "TCP", Port: 53}}},
+			},
+			winner: "a",
+			losers: map[string]string{"b": conflictReasonProtocol},
+		},
+		// port numbers only matter if compared
+		{
+			sources: []exportSource{
+				{cluster: "a", created: t0, ports: []mcs.ServicePort{{Name: "http", Protocol: "TCP", Port: 80}}},
+				{cluster: "b", created: t1, ports: []mcs.ServicePort{{Name: "http", Protocol: "TCP", Port: 8080}}},
+			},
+		},
+		{
+			sources: []exportSource{
+				{cluster: "a", created: t0, ports: []mcs.ServicePort{{Name: "http", Protocol: "TCP", Port: 80}}},
+				{cluster: "b", created: t1, ports: []mcs.ServicePort{{Name: "http", Protocol: "TCP", Port: 8080}}},
+			},
+			comparePorts: true,
+			winner:       "a",
+			losers:       map[string]string{"b": conflictReasonPort},
+		},
+		// the current winner keeps winning over older exports
+		{
+			sources: []exportSource{
+				{cluster: "a", created: t0, ports: []mcs.ServicePort{{Name: "dns", Protocol: "TCP", Port: 53}}},
+				{cluster: "b", created: t1, ports: []mcs.ServicePort{{Name: "dns", Protocol: "UDP", Port: 53}}, winning: true},
+			},
+			winner: "b",
+			losers: map[string]string{"a": conflictReasonProtocol},
+		},
+	}
+
+	for i, test := range tests {
+		c := resolveConflict("svc.ns", test.sources, test.comparePorts)
+		if test.losers == nil {
+			if c != nil {
+				t.Errorf("Test %d: expected no conflict, got %s", i, c)
+			}
+			continue
+		}
+		if c == nil {
+			t.Errorf("Test %d: expected conflict, got none", i)
+			continue
+		}
+		if c.Winner != test.winner {
+			t.Errorf("Test %d: expected winner %s, got %s", i, test.winner, c.Winner)
+		}
+		if !conflictsEqual(c, &serviceConflict{Winner: test.winner, Losers: test.losers}) {
+			t.Errorf("Test %d: expected losers %v, got %v", i, test.losers, c.Losers)
+		}
+	}
+}
+
+func TestConflictTracker(t *testing.T) {
+	tr := newConflictTracker()
+	notified := 0
+	tr.onConflict = func(*serviceConflict) { notified++ }
+
+	c := &serviceConflict{Service: "svc.ns", Winner: "a", Losers: map[string]string{"b": conflictReasonType}}
+	tr.update("svc.ns", c)
+	tr.update("svc.ns", &serviceConflict{Service: "svc.ns", Winner: "a", Losers: map[string]string{"b": conflictReasonType}})
+	if notified != 1 {
+		t.Errorf("Expected 1 notification for an unchanged conflict, got %d", notified)
+	}
+	if !tr.lost("svc.ns", "b") || tr.lost("svc.ns", "a") {
+		t.Error("Expected only cluster b to have lost")
+	}
+	if n := len(tr.list()); n != 1 {
+		t.Errorf("Expected 1 conflict, got %d", n)
+	}
+
+	tr.update("svc.ns", nil)
+	if tr.lost("svc.ns", "b") {
+		t.Error("Expected conflict to be resolved")
+	}
+}
+
+func TestCheckConflictsKeepsWinner(t *testing.T) {
+	c := &control{
+		conflicts: newConflictTracker(),
+		epLister:  cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{epNameNamespaceIndex: epNameNamespaceIndexFunc}),
+	}
+	slice := func(name, cluster, protocol string, created time.Time) *object.Endpoints {
+		return &object.Endpoints{
+			Endpoints: k8sObject.Endpoints{
+				Subsets:   []k8sObject.EndpointSubset{{Ports: []k8sObject.EndpointPort{{Name: "dns", Protocol: protocol, Port: 53}}}},
+				Name:      name,
+				Namespace: "ns",
+				Index:     object.EndpointsKey("svc", "ns"),
+			},
+			ClusterId: cluster,
+			Created:   created,
+		}
+	}
+	add := func(ep *object.Endpoints) {
+		c.epLister.Add(ep)
+		c.checkConflicts(ep)
+	}
+
+	add(slice("svc-a", "a", "TCP", time.Unix(1000, 0)))
+	add(slice("svc-b", "b", "UDP", time.Unix(2000, 0)))
+	if w := c.conflicts.winner("svc.ns"); w != "a" {
+		t.Fatalf("Expected a to win, got %q", w)
+	}
+
+	// recreating the EndpointSlice of the winner makes it the newest
+	add(slice("svc-a2", "a", "TCP", time.Unix(3000, 0)))
+	old := slice("svc-a", "a", "TCP", time.Unix(1000, 0))
+	c.epLister.Delete(old)
+	c.checkConflicts(old)
+	if w := c.conflicts.winner("svc.ns"); w != "a" {
+		t.Errorf("Expected a to keep winning, got %q", w)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,6 +17,7 @@ import (
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
@@ -60,6 +63,18 @@ type control struct {
 	epController cache.Controller
 	epLister     cache.Indexer
 
+	conflicts *conflictTracker
+	// sliceFilter rejects untrusted EndpointSlices.
+	sliceFilter sliceFilter
+	labelPolicy object.LabelPolicy
+	// serviceImportVersion is the version of the watched ServiceImports.
+	serviceImportVersion string
+
+	drainController cache.Controller
+
+	profileController  cache.Controller
+	propertyController cache.Controller
+
 	// stopLock is used to enforce only a single call to Stop is active.
 	// Needed because we allow stopping through an http endpoint and
 	// allowing concurrent stoppers leads to stack traces.
@@ -70,17 +85,50 @@ type control struct {
 
 type controllerOpts struct {
 	initEndpointsCache bool
+	// conflictEvents enables Kubernetes Events for conflicting exports.
+	conflictEvents bool
+	// drainNamespace and drainName identify the ConfigMap whose annotation drains clusters.
+	drainNamespace string
+	drainName      string
+	drain          *drainSet
+	// clusterProfiles enables the watch on the ClusterProfiles in clusterProfileNamespace, which
+	// report the health of the clusters.
+	clusterProfiles         bool
+	clusterProfileNamespace string
+	health                  *clusterHealth
+	// clusterProperties enables the watch on the ClusterProperties, which set the identity.
+	clusterProperties bool
+	identity          *clusterIdentity
+	// serviceImportVersion is the version of the ServiceImports to watch.
+	serviceImportVersion string
+	// sliceFilter rejects EndpointSlices not managed by an allowed controller or from a cluster that isn't allowed.
+	sliceFilter sliceFilter
+	// labelPolicy is applied to cluster ids and endpoint hostnames that aren't valid DNS labels.
+	labelPolicy object.LabelPolicy
 }
 
-func newController(ctx context.Context, k8sClient kubernetes.Interface, mcsClient mcsClientset.MulticlusterV1alpha1Interface, opts controllerOpts) *control {
+func newController(ctx context.Context, k8sClient kubernetes.Interface, mcsClient mcsClientset.MulticlusterV1alpha1Interface, dynamicClient dynamic.Interface, opts controllerOpts) *control {
 	ctl := control{
-		k8sClient: k8sClient,
-		mcsClient: mcsClient,
-		stopCh:    make(chan struct{}),
+		k8sClient:   k8sClient,
+		mcsClient:   mcsClient,
+		conflicts:   newConflictTracker(),
+		sliceFilter: opts.sliceFilter,
+		labelPolicy: opts.labelPolicy,
+		stopCh:      make(chan struct{}),
+	}
+	ctl.sliceFilter.rejected = newRejectedSlices()
+	if opts.conflictEvents {
+		ctl.conflicts.onConflict = ctl.conflictEvent
 	}
 
 	// enable ServiceImport watch
-	ctl.watchServiceImport(ctx)
+	ctl.serviceImportVersion = versionV1alpha1
+	if opts.serviceImportVersion != "" && opts.serviceImportVersion != versionV1alpha1 {
+		ctl.serviceImportVersion = opts.serviceImportVersion
+		ctl.watchServiceImportDynamic(ctx, dynamicClient, opts.serviceImportVersion)
+	} else {
+		ctl.watchServiceImport(ctx)
+	}
 
 	// enable Namespace watch
 	ctl.watchNamespace(ctx)
@@ -89,6 +137,18 @@ func newController(ctx context.Context, k8sClient kubernetes.Interface, mcsClien
 		ctl.watchEndpointSlice(ctx)
 	}
 
+	if opts.drainName != "" {
+		ctl.drainController = watchDrainConfigMap(ctx, k8sClient, opts.drainNamespace, opts.drainName, opts.drain, ctl.updateModified)
+	}
+
+	if opts.clusterProfiles {
+		ctl.profileController = watchClusterProfiles(ctx, dynamicClient, opts.clusterProfileNamespace, opts.health, ctl.updateModified)
+	}
+
+	if opts.clusterProperties {
+		ctl.propertyController = watchClusterProperties(ctx, dynamicClient, opts.identity, ctl.updateModified)
+	}
+
 	return &ctl
 }
 
@@ -121,13 +181,13 @@ func (c *control) watchNamespace(ctx context.Context) {
 func (c *control) watchEndpointSlice(ctx context.Context) {
 	c.epLister, c.epController = k8sObject.NewIndexerInformer(
 		&cache.ListWatch{
-			ListFunc:  endpointSliceListFunc(ctx, c.k8sClient, api.NamespaceAll),
-			WatchFunc: endpointSliceWatchFunc(ctx, c.k8sClient, api.NamespaceAll),
+			ListFunc:  endpointSliceListFunc(ctx, c.k8sClient, api.NamespaceAll, c.sliceFilter),
+			WatchFunc: endpointSliceWatchFunc(ctx, c.k8sClient, api.NamespaceAll, c.sliceFilter),
 		},
 		&discovery.EndpointSlice{},
 		cache.ResourceEventHandlerFuncs{AddFunc: c.Add, UpdateFunc: c.Update, DeleteFunc: c.Delete},
 		cache.Indexers{epNameNamespaceIndex: epNameNamespaceIndexFunc},
-		k8sObject.DefaultProcessor(object.EndpointSliceToEndpoints, nil),
+		k8sObject.DefaultProcessor(object.EndpointSliceConverter(c.labelPolicy), nil),
 	)
 }
 
@@ -151,6 +211,15 @@ func (c *control) Stop() error {
 func (c *control) Run() {
 	go c.svcImportController.Run(c.stopCh)
 	go c.nsController.Run(c.stopCh)
+	if c.drainController != nil {
+		go c.drainController.Run(c.stopCh)
+	}
+	if c.profileController != nil {
+		go c.profileController.Run(c.stopCh)
+	}
+	if c.propertyController != nil {
+		go c.propertyController.Run(c.stopCh)
+	}
 	if c.epController != nil {
 		c.epController.Run(c.stopCh)
 	}
@@ -160,6 +229,15 @@ func (c *control) Run() {
 
 // HasSynced calls on all controllers.
 func (c *control) HasSynced() bool {
+	if c.drainController != nil && !c.drainController.HasSynced() {
+		return false
+	}
+	if c.profileController != nil && !c.profileController.HasSynced() {
+		return false
+	}
+	if c.propertyController != nil && !c.propertyController.HasSynced() {
+		return false
+	}
 	return c.svcImportController.HasSynced() && c.nsController.HasSynced()
 }
 
@@ -191,18 +269,28 @@ func (c *control) ServiceList() (svcs []*object.ServiceImport) {
 }
 
 func (c *control) EndpointsList() (eps []*object.Endpoints) {
+	if c.epLister == nil {
+		// not watching endpoints, see noendpoints
+		return nil
+	}
 	os := c.epLister.List()
 	for _, o := range os {
 		ep, ok := o.(*object.Endpoints)
 		if !ok {
 			continue
 		}
+		if c.conflicts.lost(ep.Index, ep.ClusterId) {
+			continue
+		}
 		eps = append(eps, ep)
 	}
 	return eps
 }
 
 func (c *control) EpIndex(idx string) (ep []*object.Endpoints) {
+	if c.epLister == nil {
+		return nil
+	}
 	os, err := c.epLister.ByIndex(epNameNamespaceIndex, idx)
 	if err != nil {
 		return nil
@@ -212,6 +300,9 @@ func (c *control) EpIndex(idx string) (ep []*object.Endpoints) {
 		if !ok {
 			continue
 		}
+		if c.conflicts.lost(e.Index, e.ClusterId) {
+			continue
+		}
 		ep = append(ep, e)
 	}
 	return ep
@@ -241,17 +332,26 @@ func namespaceWatchFunc(ctx context.Context, c kubernetes.Interface) func(option
 	}
 }
 
-func endpointSliceListFunc(ctx context.Context, c kubernetes.Interface, ns string) func(meta.ListOptions) (runtime.Object, error) {
+func endpointSliceListFunc(ctx context.Context, c kubernetes.Interface, ns string, filter sliceFilter) func(meta.ListOptions) (runtime.Object, error) {
 	return func(opts meta.ListOptions) (runtime.Object, error) {
 		opts.LabelSelector = mcs.LabelServiceName // only slices created by MCS controller
-		return c.DiscoveryV1().EndpointSlices(ns).List(ctx, opts)
+		list, err := c.DiscoveryV1().EndpointSlices(ns).List(ctx, opts)
+		if err != nil || !filter.enabled() {
+			return list, err
+		}
+		filter.filterList(list)
+		return list, nil
 	}
 }
 
-func endpointSliceWatchFunc(ctx context.Context, c kubernetes.Interface, ns string) func(options meta.ListOptions) (watch.Interface, error) {
+func endpointSliceWatchFunc(ctx context.Context, c kubernetes.Interface, ns string, filter sliceFilter) func(options meta.ListOptions) (watch.Interface, error) {
 	return func(opts meta.ListOptions) (watch.Interface, error) {
 		opts.LabelSelector = mcs.LabelServiceName // only slices created by MCS controller
-		return c.DiscoveryV1().EndpointSlices(ns).Watch(ctx, opts)
+		w, err := c.DiscoveryV1().EndpointSlices(ns).Watch(ctx, opts)
+		if err != nil || !filter.enabled() {
+			return w, err
+		}
+		return filter.filterWatch(w), nil
 	}
 }
 
@@ -271,9 +371,98 @@ func (c *control) GetNamespaceByName(name string) (*k8sObject.Namespace, error)
 	return ns, nil
 }
 
-func (c *control) Add(obj interface{})               { c.updateModified() }
-func (c *control) Delete(obj interface{})            { c.updateModified() }
-func (c *control) Update(oldObj, newObj interface{}) { c.detectChanges(oldObj, newObj) }
+// NamespaceList returns the names of all namespaces.
+func (c *control) NamespaceList() []string { return namespaceNames(c.nsLister) }
+
+// Conflicts returns the conflicts currently detected between the exports of services.
+func (c *control) Conflicts() []*serviceConflict { return c.conflicts.list() }
+
+// namespaceNames returns the sorted names of the namespaces in stores.
+func namespaceNames(stores ...cache.Store) []string {
+	seen := map[string]struct{}{}
+	for _, s := range stores {
+		for _, key := range s.ListKeys() {
+			seen[key] = struct{}{}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *control) Add(obj interface{})    { c.updateModified(); c.checkConflicts(obj) }
+func (c *control) Delete(obj interface{}) { c.updateModified(); c.checkConflicts(obj) }
+func (c *control) Update(oldObj, newObj interface{}) {
+	c.detectChanges(oldObj, newObj)
+	c.checkConflicts(newObj)
+}
+
+// checkConflicts detects conflicts between the clusters exporting the service of obj, if obj is an
+// Endpoints. EndpointSlice ports are target ports which may differ between clusters, so only protocols
+// are compared. The export time of a cluster is taken from its oldest EndpointSlice, which changes
+// when its EndpointSlices are recreated, so the winner of a conflict keeps winning as long as it has
+// EndpointSlices.
+func (c *control) checkConflicts(obj interface{}) {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+	e, ok := obj.(*object.Endpoints)
+	if !ok || c.epLister == nil {
+		return
+	}
+	os, err := c.epLister.ByIndex(epNameNamespaceIndex, e.Index)
+	if err != nil {
+		return
+	}
+	sources := map[string]*exportSource{}
+	for _, o := range os {
+		ep, ok := o.(*object.Endpoints)
+		if !ok {
+			continue
+		}
+		s, ok := sources[ep.ClusterId]
+		if !ok {
+			s = &exportSource{cluster: ep.ClusterId, created: ep.Created}
+			sources[ep.ClusterId] = s
+		}
+		if ep.Created.Before(s.created) {
+			s.created = ep.Created
+		}
+		for _, sub := range ep.Subsets {
+			for _, p := range sub.Ports {
+				if p.Port == -1 {
+					continue
+				}
+				s.ports = append(s.ports, mcs.ServicePort{Name: p.Name, Protocol: api.Protocol(p.Protocol), Port: p.Port})
+			}
+		}
+	}
+	if s, ok := sources[c.conflicts.winner(e.Index)]; ok {
+		s.winning = true
+	}
+	list := make([]exportSource, 0, len(sources))
+	for _, s := range sources {
+		list = append(list, *s)
+	}
+	c.conflicts.update(e.Index, resolveConflict(e.Index, list, false))
+}
+
+// conflictEvent emits an event for the conflict on the ServiceImport.
+func (c *control) conflictEvent(sc *serviceConflict) {
+	name, namespace, ok := strings.Cut(sc.Service, ".")
+	if !ok {
+		return
+	}
+	emitConflictEvent(c.k8sClient, api.ObjectReference{
+		Kind:       "ServiceImport",
+		APIVersion: mcs.GroupVersion.Group + "/" + c.serviceImportVersion,
+		Name:       name,
+		Namespace:  namespace,
+	}, sc)
+}
 
 // detectChanges detects changes in objects, and updates the modified timestamp
 func (c *control) detectChanges(oldObj, newObj interface{}) {
@@ -0,0 +1,195 @@
+package multicluster
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/reuseport"
+	"github.com/coredns/multicluster/object"
+	"github.com/miekg/dns"
+)
+
+const defaultDebugAddress = "localhost:9154"
+
+// namespaceLister is implemented by controllers that can list the namespaces they know of.
+type namespaceLister interface {
+	NamespaceList() []string
+}
+
+// conflictLister is implemented by controllers that detect conflicts between exports.
+type conflictLister interface {
+	Conflicts() []*serviceConflict
+}
+
+// debugServer serves the data of the plugin as JSON over HTTP, to help troubleshooting.
+type debugServer struct {
+	addr string
+	m    *MultiCluster
+	srv  *http.Server
+}
+
+func newDebugServer(addr string, m *MultiCluster) *debugServer {
+	return &debugServer{addr: addr, m: m}
+}
+
+// Start starts listening on the address of d.
+func (d *debugServer) Start() error {
+	ln, err := reuseport.Listen("tcp", d.addr)
+	if err != nil {
+		return err
+	}
+	d.srv = &http.Server{Handler: d.handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := d.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Errorf("Debug server on %s failed: %v", d.addr, err)
+		}
+	}()
+	log.Infof("Serving debug data on %s", d.addr)
+	return nil
+}
+
+// Stop stops d, waiting for pending requests for at most a few seconds.
+func (d *debugServer) Stop() error {
+	if d.srv == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return d.srv.Shutdown(ctx)
+}
+
+func (d *debugServer) handler() http.Handler {
+	ctl := func() controller { return d.m.controller }
+	mux := http.NewServeMux()
+	mux.HandleFunc("/services", func(w http.ResponseWriter, r *http.Request) {
+		svcs := ctl().ServiceList()
+		if svcs == nil {
+			svcs = []*object.ServiceImport{}
+		}
+		writeJSON(w, http.StatusOK, svcs)
+	})
+	mux.HandleFunc("/endpoints", func(w http.ResponseWriter, r *http.Request) {
+		eps := ctl().EndpointsList()
+		if eps == nil {
+			eps = []*object.Endpoints{}
+		}
+		writeJSON(w, http.StatusOK, eps)
+	})
+	mux.HandleFunc("/namespaces", func(w http.ResponseWriter, r *http.Request) {
+		l, ok := ctl().(namespaceLister)
+		if !ok {
+			http.Error(w, "namespaces can't be listed", http.StatusNotImplemented)
+			return
+		}
+		writeJSON(w, http.StatusOK, l.NamespaceList())
+	})
+	mux.HandleFunc("/conflicts", func(w http.ResponseWriter, r *http.Request) {
+		conflicts := []*serviceConflict{}
+		if l, ok := ctl().(conflictLister); ok {
+			for _, c := range l.Conflicts() {
+				conflicts = append(conflicts, d.m.aliases.labelConflict(c))
+			}
+		}
+		writeJSON(w, http.StatusOK, conflicts)
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, d.m.pluginStatus())
+	})
+	mux.HandleFunc("/resolve", d.resolve)
+	return mux
+}
+
+// resolvedQuery is the answer to a query made through the debug server.
+type resolvedQuery struct {
+	Rcode  string   `json:"rcode"`
+	Answer []string `json:"answer"`
+	Ns     []string `json:"ns"`
+	Extra  []string `json:"extra"`
+}
+
+// resolve answers the query for the name and type (A by default) parameters of r as ServeDNS
+// would. The client parameter sets the address of the client, the loopback address by default.
+func (d *debugServer) resolve(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "missing name", http.StatusBadRequest)
+		return
+	}
+	qtype := dns.TypeA
+	if t := r.URL.Query().Get("type"); t != "" {
+		var ok bool
+		if qtype, ok = dns.StringToType[strings.ToUpper(t)]; !ok {
+			http.Error(w, "unknown type "+t, http.StatusBadRequest)
+			return
+		}
+	}
+	client := net.IPv4(127, 0, 0, 1)
+	if c := r.URL.Query().Get("client"); c != "" {
+		if client = net.ParseIP(c); client == nil {
+			http.Error(w, "invalid client "+c, http.StatusBadRequest)
+			return
+		}
+	}
+
+	req := new(dns.Msg)
+	req.SetQuestion(dns.Fqdn(name), qtype)
+	rw := &debugWriter{client: client}
+	rcode, err := d.m.ServeDNS(r.Context(), rw, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res := resolvedQuery{Rcode: dns.RcodeToString[rcode]}
+	if rw.msg != nil {
+		res.Rcode = dns.RcodeToString[rw.msg.Rcode]
+		res.Answer, res.Ns, res.Extra = rrStrings(rw.msg.Answer), rrStrings(rw.msg.Ns), rrStrings(rw.msg.Extra)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func rrStrings(rrs []dns.RR) []string {
+	s := make([]string, len(rrs))
+	for i, rr := range rrs {
+		s[i] = rr.String()
+	}
+	return s
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warningf("Failed to write debug response: %v", err)
+	}
+}
+
+// debugWriter is a dns.ResponseWriter keeping the message written, for queries made through the
+// debug server.
+type debugWriter struct {
+	client net.IP
+	msg    *dns.Msg
+}
+
+func (w *debugWriter) LocalAddr() net.Addr  { return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53} }
+func (w *debugWriter) RemoteAddr() net.Addr { return &net.UDPAddr{IP: w.client, Port: 53} }
+func (w *debugWriter) WriteMsg(m *dns.Msg) error {
+	w.msg = m
+	return nil
+}
+func (w *debugWriter) Write(buf []byte) (int, error) {
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return 0, err
+	}
+	w.msg = m
+	return len(buf), nil
+}
+func (w *debugWriter) Close() error        { return nil }
+func (w *debugWriter) TsigStatus() error   { return nil }
+func (w *debugWriter) TsigTimersOnly(bool) {}
+func (w *debugWriter) Hijack()             {}
@@ -0,0 +1,139 @@
+package multicluster
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	k8sObject "github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/multicluster/object"
+	"k8s.io/client-go/tools/cache"
+)
+
+// debugControllerMock adds namespaces and conflicts to controllerMock2.
+type debugControllerMock struct {
+	controllerMock2
+}
+
+func (debugControllerMock) NamespaceList() []string { return []string{"kube-system", "testns"} }
+
+func (debugControllerMock) Conflicts() []*serviceConflict {
+	return []*serviceConflict{{Service: "hdls1.testns", Winner: "c2", Losers: map[string]string{"c1": conflictReasonType}}}
+}
+
+func getJSON(t *testing.T, h http.Handler, url string, v interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("Invalid JSON for %s: %v", url, err)
+		}
+	}
+	return w.Code
+}
+
+func TestDebugServer(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &debugControllerMock{}
+	m.aliases.add("c1", "east")
+	h := newDebugServer(defaultDebugAddress, m).handler()
+
+	var svcs []*object.ServiceImport
+	if code := getJSON(t, h, "/services", &svcs); code != http.StatusOK || len(svcs) != len(m.controller.ServiceList()) {
+		t.Errorf("Expected %d services, got %d (%d)", len(m.controller.ServiceList()), len(svcs), code)
+	}
+	var eps []*object.Endpoints
+	if code := getJSON(t, h, "/endpoints", &eps); code != http.StatusOK || len(eps) != len(m.controller.EndpointsList()) {
+		t.Errorf("Expected %d endpoints, got %d (%d)", len(m.controller.EndpointsList()), len(eps), code)
+	}
+	var namespaces []string
+	if code := getJSON(t, h, "/namespaces", &namespaces); code != http.StatusOK || len(namespaces) != 2 {
+		t.Errorf("Expected 2 namespaces, got %v (%d)", namespaces, code)
+	}
+	var conflicts []*serviceConflict
+	if code := getJSON(t, h, "/conflicts", &conflicts); code != http.StatusOK || len(conflicts) != 1 || conflicts[0].Winner != "c2" || conflicts[0].Losers["east"] == "" {
+		t.Errorf("Expected the conflict of hdls1, got %v (%d)", conflicts, code)
+	}
+	var status pluginStatus
+	if code := getJSON(t, h, "/status", &status); code != http.StatusOK || !status.Synced || status.Imports != len(svcs) {
+		t.Errorf("Expected a synced status, got %+v (%d)", status, code)
+	}
+}
+
+func TestDebugServerOptionalInterfaces(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	h := newDebugServer(defaultDebugAddress, m).handler()
+
+	var namespaces []string
+	if code := getJSON(t, h, "/namespaces", &namespaces); code != http.StatusNotImplemented {
+		t.Errorf("Expected namespaces to not be listed, got %d", code)
+	}
+	var conflicts []*serviceConflict
+	if code := getJSON(t, h, "/conflicts", &conflicts); code != http.StatusOK || len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts, got %v (%d)", conflicts, code)
+	}
+}
+
+func TestDebugResolve(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	h := newDebugServer(defaultDebugAddress, m).handler()
+
+	var res resolvedQuery
+	if code := getJSON(t, h, "/resolve?name=svc1.testns.svc.cluster.local", &res); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if res.Rcode != "NOERROR" || len(res.Answer) != 1 || res.Answer[0] != "svc1.testns.svc.cluster.local.\t5\tIN\tA\t10.0.0.1" {
+		t.Errorf("Expected the ClusterSetIP of svc1, got %+v", res)
+	}
+
+	if code := getJSON(t, h, "/resolve?name=nosvc.testns.svc.cluster.local&type=srv", &res); code != http.StatusOK || res.Rcode != "NXDOMAIN" {
+		t.Errorf("Expected NXDOMAIN, got %+v (%d)", res, code)
+	}
+
+	for _, url := range []string{"/resolve", "/resolve?name=svc1.testns.svc.cluster.local&type=bogus", "/resolve?name=svc1.testns.svc.cluster.local&client=bogus"} {
+		if code := getJSON(t, h, url, &res); code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", url, code)
+		}
+	}
+}
+
+func TestDebugServerStartStop(t *testing.T) {
+	d := newDebugServer("127.0.0.1:0", New([]string{"cluster.local."}))
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Stop(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNamespaceNames(t *testing.T) {
+	s1, s2 := cache.NewStore(cache.MetaNamespaceKeyFunc), cache.NewStore(cache.MetaNamespaceKeyFunc)
+	s1.Add(&k8sObject.Namespace{Name: "testns"})
+	s2.Add(&k8sObject.Namespace{Name: "kube-system"})
+	s2.Add(&k8sObject.Namespace{Name: "testns"})
+
+	names := namespaceNames(s1, s2)
+	if len(names) != 2 || names[0] != "kube-system" || names[1] != "testns" {
+		t.Errorf("Expected kube-system and testns, got %v", names)
+	}
+}
+
+func TestDebugServerNoEndpoints(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = newControllerWithoutEndpoints(t)
+	h := newDebugServer(defaultDebugAddress, m).handler()
+
+	var eps []*object.Endpoints
+	if code := getJSON(t, h, "/endpoints", &eps); code != http.StatusOK || len(eps) != 0 {
+		t.Errorf("Expected no endpoints, got %v (%d)", eps, code)
+	}
+	var status pluginStatus
+	if code := getJSON(t, h, "/status", &status); code != http.StatusOK || status.EndpointSlices != 0 || status.Imports != 1 {
+		t.Errorf("Expected a status without EndpointSlices, got %+v (%d)", status, code)
+	}
+}
@@ -0,0 +1,156 @@
+package multicluster
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/coredns/coredns/request"
+	"github.com/coredns/multicluster/object"
+	"github.com/miekg/dns"
+)
+
+// browseRequest is a DNS-SD (RFC 6763) query in a namespace: for the service types if port is
+// empty, for the instances of the type _port._protocol if instance is empty, or else for the
+// instance itself.
+type browseRequest struct {
+	instance  string
+	port      string
+	protocol  string
+	namespace string
+}
+
+// parseBrowseRequest parses the DNS-SD names
+//
+//	_services._dns-sd._udp.namespace.svc.zone
+//	_port._protocol.namespace.svc.zone
+//	service._port._protocol.namespace.svc.zone
+//
+// and returns false for any other name.
+func parseBrowseRequest(name, zone string) (b browseRequest, ok bool) {
+	base, _ := dnsutil.TrimZone(strings.ToLower(name), strings.ToLower(zone))
+	segs := dns.SplitDomainName(base)
+	n := len(segs)
+	if n < 4 || segs[n-1] != Svc {
+		return b, false
+	}
+	b.namespace = segs[n-2]
+
+	switch {
+	case n == 5 && segs[0] == "_services" && segs[1] == "_dns-sd" && segs[2] == "_udp":
+		return b, true
+	case n == 4 && isServiceLabel(segs[0]) && isServiceLabel(segs[1]):
+		b.port, b.protocol = segs[0][1:], segs[1][1:]
+		return b, true
+	case n == 5 && !isServiceLabel(segs[0]) && isServiceLabel(segs[1]) && isServiceLabel(segs[2]):
+		b.instance, b.port, b.protocol = segs[0], segs[1][1:], segs[2][1:]
+		return b, true
+	}
+	return b, false
+}
+
+func isServiceLabel(s string) bool { return len(s) > 1 && s[0] == '_' }
+
+// serveBrowse answers the DNS-SD query of state, which is in zone.
+func (m *MultiCluster) serveBrowse(ctx context.Context, w dns.ResponseWriter, state request.Request, b browseRequest, zone string) (int, error) {
+	records, err := m.browse(state, b, zone)
+	if m.IsNameError(err) {
+		if m.Fall.Through(state.Name()) {
+			return plugin.NextOrFailure(m.Name(), m.Next, ctx, nextWriter(w), state.Req)
+		}
+		return plugin.BackendError(ctx, m, zone, dns.RcodeNameError, state, nil, plugin.Options{})
+	}
+	if len(records) == 0 {
+		return plugin.BackendError(ctx, m, zone, dns.RcodeSuccess, state, nil, plugin.Options{})
+	}
+
+	message := new(dns.Msg)
+	message.SetReply(state.Req)
+	message.Authoritative = true
+	message.Answer = records
+	w.WriteMsg(message)
+	return dns.RcodeSuccess, nil
+}
+
+// browse returns the records answering the DNS-SD query of state. Service types are the named ports
+// of the ServiceImports in the namespace, and every ServiceImport with such a port is an instance.
+func (m *MultiCluster) browse(state request.Request, b browseRequest, zone string) ([]dns.RR, error) {
+	if !m.namespaceExists(b.namespace) {
+		return nil, errNoItems
+	}
+	suffix := b.namespace + "." + Svc + "." + zone
+	hdr := func(rrtype uint16) dns.RR_Header {
+		return dns.RR_Header{Name: state.QName(), Rrtype: rrtype, Class: dns.ClassINET, Ttl: m.ttl}
+	}
+
+	types := map[string]struct{}{}
+	var instances []*object.ServiceImport
+	for _, svc := range m.controller.ServiceList() {
+		if svc.Namespace != b.namespace {
+			continue
+		}
+		for _, p := range svc.Ports {
+			if p.Name == "" {
+				continue
+			}
+			protocol := strings.ToLower(string(p.Protocol))
+			types["_"+p.Name+"._"+protocol] = struct{}{}
+			if p.Name != b.port || protocol != b.protocol {
+				continue
+			}
+			if b.instance == "" || b.instance == svc.Name {
+				instances = append(instances, svc)
+			}
+		}
+	}
+
+	var records []dns.RR
+	switch {
+	case b.port == "":
+		if state.QType() != dns.TypePTR {
+			return nil, nil
+		}
+		names := make([]string, 0, len(types))
+		for t := range types {
+			names = append(names, t)
+		}
+		sort.Strings(names)
+		for _, t := range names {
+			records = append(records, &dns.PTR{Hdr: hdr(dns.TypePTR), Ptr: t + "." + suffix})
+		}
+	case len(instances) == 0:
+		return nil, errNoItems
+	case b.instance == "":
+		if state.QType() != dns.TypePTR {
+			return nil, nil
+		}
+		sort.Slice(instances, func(i, j int) bool { return instances[i].Name < instances[j].Name })
+		for i, svc := range instances {
+			if i > 0 && svc.Name == instances[i-1].Name {
+				continue
+			}
+			records = append(records, &dns.PTR{Hdr: hdr(dns.TypePTR), Ptr: svc.Name + "._" + b.port + "._" + b.protocol + "." + suffix})
+		}
+	default:
+		switch state.QType() {
+		case dns.TypeSRV:
+			for _, svc := range instances {
+				target := svc.Name + "." + suffix
+				if svc.ExternalName != "" {
+					target = svc.ExternalName
+				}
+				for _, p := range svc.Ports {
+					if p.Name == b.port && strings.ToLower(string(p.Protocol)) == b.protocol {
+						records = append(records, &dns.SRV{Hdr: hdr(dns.TypeSRV), Priority: 0, Weight: 100, Port: uint16(p.Port), Target: target})
+					}
+				}
+			}
+		case dns.TypeTXT:
+			// every instance must have a TXT record, a single empty string if there is nothing to say
+			records = append(records, &dns.TXT{Hdr: hdr(dns.TypeTXT), Txt: []string{""}})
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,134 @@
+package multicluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+)
+
+func TestParseBrowseRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		ok       bool
+		expected browseRequest
+	}{
+		{"_services._dns-sd._udp.testns.svc.cluster.local.", true, browseRequest{namespace: "testns"}},
+		{"_http._tcp.testns.svc.cluster.local.", true, browseRequest{port: "http", protocol: "tcp", namespace: "testns"}},
+		{"svc1._HTTP._tcp.testns.svc.cluster.local.", true, browseRequest{instance: "svc1", port: "http", protocol: "tcp", namespace: "testns"}},
+		{"_http._tcp.svc1.testns.svc.cluster.local.", false, browseRequest{}},
+		{"svc1.testns.svc.cluster.local.", false, browseRequest{}},
+		{"_http._tcp.testns.pod.cluster.local.", false, browseRequest{}},
+	}
+	for i, tc := range tests {
+		b, ok := parseBrowseRequest(tc.name, "cluster.local.")
+		if ok != tc.ok {
+			t.Errorf("Test %d: expected %t, got %t", i, tc.ok, ok)
+			continue
+		}
+		if ok && b != tc.expected {
+			t.Errorf("Test %d: expected %+v, got %+v", i, tc.expected, b)
+		}
+	}
+}
+
+var dnssdTestCases = []test.Case{
+	// the service types of the namespace
+	{
+		Qname: "_services._dns-sd._udp.kube-system.svc.cluster.local.", Qtype: dns.TypePTR,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.PTR("_services._dns-sd._udp.kube-system.svc.cluster.local.	5	IN	PTR	_dns._udp.kube-system.svc.cluster.local."),
+		},
+	},
+	// the instances of a type
+	{
+		Qname: "_dns._udp.kube-system.svc.cluster.local.", Qtype: dns.TypePTR,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.PTR("_dns._udp.kube-system.svc.cluster.local.	5	IN	PTR	kubedns._dns._udp.kube-system.svc.cluster.local."),
+		},
+	},
+	// an instance
+	{
+		Qname: "kubedns._dns._udp.kube-system.svc.cluster.local.", Qtype: dns.TypeSRV,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.SRV("kubedns._dns._udp.kube-system.svc.cluster.local.	5	IN	SRV	0 100 53 kubedns.kube-system.svc.cluster.local."),
+		},
+	},
+	{
+		Qname: "kubedns._dns._udp.kube-system.svc.cluster.local.", Qtype: dns.TypeTXT,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.TXT(`kubedns._dns._udp.kube-system.svc.cluster.local.	5	IN	TXT	""`),
+		},
+	},
+	// NODATA for other types
+	{
+		Qname: "kubedns._dns._udp.kube-system.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+	// NXDOMAIN for unknown types and instances
+	{
+		Qname: "_ftp._tcp.kube-system.svc.cluster.local.", Qtype: dns.TypePTR,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+	{
+		Qname: "svc1._dns._udp.kube-system.svc.cluster.local.", Qtype: dns.TypeSRV,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+	// and namespaces
+	{
+		Qname: "_services._dns-sd._udp.nsnoexist.svc.cluster.local.", Qtype: dns.TypePTR,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+}
+
+func TestBrowseServeDNS(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	m.dnsSD = true
+	ctx := context.TODO()
+
+	for i, tc := range dnssdTestCases {
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := m.ServeDNS(ctx, w, tc.Msg()); err != nil {
+			t.Errorf("Test %d expected no error, got %v", i, err)
+			continue
+		}
+		if err := test.SortAndCheck(w.Msg, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+	}
+}
+
+func TestBrowseDisabled(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	r := new(dns.Msg).SetQuestion("_services._dns-sd._udp.kube-system.svc.cluster.local.", dns.TypePTR)
+	if _, err := m.ServeDNS(context.TODO(), w, r); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if w.Msg.Rcode != dns.RcodeNameError {
+		t.Errorf("Expected NXDOMAIN without dns_sd, got %s", dns.RcodeToString[w.Msg.Rcode])
+	}
+}
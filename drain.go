@@ -0,0 +1,110 @@
+package multicluster
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	api "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// drainAnnotation on the drain ConfigMap holds a comma separated list of drained cluster ids.
+const drainAnnotation = "multicluster.coredns.io/drain"
+
+// drainSet holds the cluster ids whose endpoints are left out of answers, e.g. during maintenance.
+type drainSet struct {
+	// static are the clusters drained in the Corefile.
+	static map[string]struct{}
+	// allowExplicit keeps drained endpoints resolvable through hostname.clusterid queries.
+	allowExplicit bool
+
+	sync.RWMutex
+	// dynamic are the clusters drained by the annotation on the drain ConfigMap.
+	dynamic map[string]struct{}
+}
+
+func newDrainSet() *drainSet {
+	return &drainSet{static: map[string]struct{}{}, dynamic: map[string]struct{}{}}
+}
+
+// drained returns true if cluster is drained.
+func (d *drainSet) drained(cluster string) bool {
+	if _, ok := d.static[cluster]; ok {
+		return true
+	}
+	d.RLock()
+	defer d.RUnlock()
+	_, ok := d.dynamic[cluster]
+	return ok
+}
+
+// skip returns true if the endpoints of cluster must be left out of the answer. Explicit
+// endpoint queries are still answered for drained clusters if allowExplicit is set.
+func (d *drainSet) skip(cluster string, explicit bool) bool {
+	if explicit && d.allowExplicit {
+		return false
+	}
+	return d.drained(cluster)
+}
+
+// setDynamic replaces the clusters drained by the ConfigMap with the ones listed in value.
+func (d *drainSet) setDynamic(value string) {
+	dynamic := map[string]struct{}{}
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			dynamic[id] = struct{}{}
+		}
+	}
+	d.Lock()
+	changed := len(dynamic) != len(d.dynamic)
+	for id := range dynamic {
+		if _, ok := d.dynamic[id]; !ok {
+			changed = true
+		}
+	}
+	d.dynamic = dynamic
+	d.Unlock()
+
+	if changed {
+		log.Infof("Drained clusters set to %q by ConfigMap", value)
+	}
+}
+
+// watchDrainConfigMap returns an informer keeping the dynamically drained clusters of d in sync
+// with the annotation on the ConfigMap namespace/name.
+func watchDrainConfigMap(ctx context.Context, client kubernetes.Interface, namespace, name string, d *drainSet, onChange func()) cache.Controller {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	update := func(obj interface{}) {
+		cm, ok := obj.(*api.ConfigMap)
+		if !ok {
+			return
+		}
+		d.setDynamic(cm.GetAnnotations()[drainAnnotation])
+		onChange()
+	}
+	_, ctl := cache.NewInformerWithOptions(cache.InformerOptions{
+		ListerWatcher: &cache.ListWatch{
+			ListFunc: func(o meta.ListOptions) (runtime.Object, error) {
+				o.FieldSelector = selector
+				return client.CoreV1().ConfigMaps(namespace).List(ctx, o)
+			},
+			WatchFunc: func(o meta.ListOptions) (watch.Interface, error) {
+				o.FieldSelector = selector
+				return client.CoreV1().ConfigMaps(namespace).Watch(ctx, o)
+			},
+		},
+		ObjectType: &api.ConfigMap{},
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc:    update,
+			UpdateFunc: func(oldObj, newObj interface{}) { update(newObj) },
+			DeleteFunc: func(interface{}) { d.setDynamic(""); onChange() },
+		},
+	})
+	return ctl
+}
@@ -0,0 +1,126 @@
+package multicluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+	api "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDrainSet(t *testing.T) {
+	d := newDrainSet()
+	d.static["static"] = struct{}{}
+	d.setDynamic(" dyn1, dyn2 ,")
+
+	for _, id := range []string{"static", "dyn1", "dyn2"} {
+		if !d.drained(id) {
+			t.Errorf("Expected %s to be drained", id)
+		}
+	}
+	if d.drained("other") {
+		t.Error("Expected other to not be drained")
+	}
+	if !d.skip("dyn1", true) {
+		t.Error("Expected explicit queries to be skipped for drained clusters")
+	}
+	d.allowExplicit = true
+	if d.skip("dyn1", true) || !d.skip("dyn1", false) {
+		t.Error("Expected only explicit queries to be answered for drained clusters")
+	}
+
+	d.setDynamic("")
+	if d.drained("dyn1") || !d.drained("static") {
+		t.Error("Expected only the static cluster to remain drained")
+	}
+}
+
+func TestWatchDrainConfigMap(t *testing.T) {
+	cm := &api.ConfigMap{ObjectMeta: meta.ObjectMeta{
+		Name:        "multicluster",
+		Namespace:   "kube-system",
+		Annotations: map[string]string{drainAnnotation: "c1"},
+	}}
+	client := fake.NewSimpleClientset(cm)
+	d := newDrainSet()
+	ctl := watchDrainConfigMap(context.TODO(), client, "kube-system", "multicluster", d, func() {})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go ctl.Run(stopCh)
+
+	waitFor := func(cond func() bool, msg string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatal(msg)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor(func() bool { return d.drained("c1") }, "Expected c1 to be drained")
+
+	cm.Annotations[drainAnnotation] = "c2"
+	if _, err := client.CoreV1().ConfigMaps("kube-system").Update(context.TODO(), cm, meta.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(func() bool { return d.drained("c2") && !d.drained("c1") }, "Expected only c2 to be drained")
+}
+
+var drainTestCases = []test.Case{
+	// Drained clusters are left out of headless answers
+	{
+		Qname: "hdls1.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+	// but can still be queried explicitly
+	{
+		Qname: "172-0-0-2.clusterid.hdls1.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("172-0-0-2.clusterid.hdls1.testns.svc.cluster.local.	5	IN	A	172.0.0.2"),
+		},
+	},
+	// ClusterSetIP services are unaffected
+	{
+		Qname: "svc1.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("svc1.testns.svc.cluster.local.	5	IN	A	10.0.0.1"),
+		},
+	},
+}
+
+func TestDrainServeDNS(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	m.drain.static["clusterid"] = struct{}{}
+	m.drain.allowExplicit = true
+	ctx := context.TODO()
+
+	for i, tc := range drainTestCases {
+		r := tc.Msg()
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+
+		if _, err := m.ServeDNS(ctx, w, r); err != nil {
+			t.Errorf("Test %d expected no error, got %v", i, err)
+			continue
+		}
+		resp := w.Msg
+		if resp == nil {
+			t.Fatalf("Test %d, got nil message and no error for %q", i, r.Question[0].Name)
+		}
+		if err := test.SortAndCheck(resp, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+	}
+}
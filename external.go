@@ -0,0 +1,64 @@
+package multicluster
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/request"
+	"github.com/coredns/multicluster/object"
+	"github.com/miekg/dns"
+)
+
+// lookuper resolves names outside of the zones of the plugin, e.g. the targets of CNAMEs.
+type lookuper interface {
+	Lookup(ctx context.Context, state request.Request, name string, typ uint16) (*dns.Msg, error)
+}
+
+// externalServices returns the services of a ServiceImport with an external name, one for every port
+// matching r. They make ServeDNS answer with a CNAME to the external name.
+func (m *MultiCluster) externalServices(svc *object.ServiceImport, r recordRequest, zonePath string) []msg.Service {
+	key := strings.Join([]string{zonePath, Svc, svc.Namespace, svc.Name}, "/")
+	if len(svc.Ports) == 0 && r.port == "" && r.protocol == "" {
+		return []msg.Service{{Host: svc.ExternalName, TTL: m.ttl, Key: key}}
+	}
+	var services []msg.Service
+	for _, p := range svc.Ports {
+		if !matchPortAndProtocol(r.port, p.Name, r.protocol, string(p.Protocol)) {
+			continue
+		}
+		services = append(services, msg.Service{Host: svc.ExternalName, Port: int(p.Port), TTL: m.ttl, Key: key})
+	}
+	return services
+}
+
+// singleTarget keeps a single CNAME target in services, as a name can't have several CNAMEs. Addresses
+// take precedence over CNAME targets. SRV answers keep all ports of the target.
+func singleTarget(services []msg.Service, qtype uint16) []msg.Service {
+	target, addresses := "", false
+	for _, s := range services {
+		if s.Host == "" {
+			continue
+		}
+		if net.ParseIP(s.Host) != nil {
+			addresses = true
+		} else if target == "" {
+			target = s.Host
+		}
+	}
+	if target == "" {
+		return services
+	}
+
+	kept := services[:0]
+	for _, s := range services {
+		if addresses && net.ParseIP(s.Host) != nil || !addresses && s.Host == target {
+			kept = append(kept, s)
+		}
+	}
+	if !addresses && qtype != dns.TypeSRV && len(kept) > 1 {
+		kept = kept[:1]
+	}
+	return kept
+}
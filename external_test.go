@@ -0,0 +1,158 @@
+package multicluster
+
+import (
+	"context"
+	"testing"
+
+	k8sObject "github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+	"github.com/coredns/multicluster/object"
+	"github.com/miekg/dns"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// externalControllerMock adds a ServiceImport with an external name, and a headless service with
+// FQDN endpoints to controllerMock2.
+type externalControllerMock struct {
+	controllerMock2
+}
+
+var externalSvcIndex = map[string][]*object.ServiceImport{
+	"ext1.testns": {{
+		Name:         "ext1",
+		Namespace:    "testns",
+		Index:        "ext1.testns",
+		Type:         mcs.ClusterSetIP,
+		ClusterIPs:   []string{"10.0.0.10"},
+		Ports:        []mcs.ServicePort{{Name: "http", Protocol: "TCP", Port: 80}, {Name: "https", Protocol: "TCP", Port: 443}},
+		ExternalName: "lb.example.org.",
+	}},
+	"ext2.testns": {{
+		Name:         "ext2",
+		Namespace:    "testns",
+		Index:        "ext2.testns",
+		Type:         mcs.ClusterSetIP,
+		ExternalName: "svc1.testns.svc.example.local.",
+	}},
+	"fqdn1.testns": {{
+		Name:      "fqdn1",
+		Namespace: "testns",
+		Index:     "fqdn1.testns",
+		Type:      mcs.Headless,
+	}},
+}
+
+var externalEpsIndex = map[string][]*object.Endpoints{
+	"fqdn1.testns": {{
+		Endpoints: k8sObject.Endpoints{
+			Subsets: []k8sObject.EndpointSubset{{
+				Addresses: []k8sObject.EndpointAddress{{IP: "lb1.example.org"}, {IP: "lb2.example.org"}},
+				Ports:     []k8sObject.EndpointPort{{Port: 80, Protocol: "tcp", Name: "http"}},
+			}},
+			Name:      "fqdn1-slice1",
+			Namespace: "testns",
+			Index:     object.EndpointsKey("fqdn1", "testns"),
+		},
+		ClusterId: "clusterid",
+		FQDN:      true,
+	}},
+}
+
+func (c externalControllerMock) SvcIndex(s string) []*object.ServiceImport {
+	if svcs, ok := externalSvcIndex[s]; ok {
+		return svcs
+	}
+	return c.controllerMock2.SvcIndex(s)
+}
+
+func (c externalControllerMock) EpIndex(s string) []*object.Endpoints {
+	if eps, ok := externalEpsIndex[s]; ok {
+		return eps
+	}
+	return c.controllerMock2.EpIndex(s)
+}
+
+// upstreamMock answers A queries for every name with 192.0.2.1.
+type upstreamMock struct{}
+
+func (upstreamMock) Lookup(ctx context.Context, state request.Request, name string, typ uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, typ)
+	if typ == dns.TypeA {
+		m.Answer = []dns.RR{test.A(name + "	5	IN	A	192.0.2.1")}
+	}
+	return m, nil
+}
+
+var externalTestCases = []test.Case{
+	// the external name is chased through Lookup
+	{
+		Qname: "ext1.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.CNAME("ext1.testns.svc.cluster.local.	5	IN	CNAME	lb.example.org."),
+			test.A("lb.example.org.	5	IN	A	192.0.2.1"),
+		},
+	},
+	{
+		Qname: "_https._tcp.ext1.testns.svc.cluster.local.", Qtype: dns.TypeSRV,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.SRV("_https._tcp.ext1.testns.svc.cluster.local.	5	IN	SRV	0 100 443 lb.example.org."),
+		},
+		Extra: []dns.RR{
+			test.A("lb.example.org.	5	IN	A	192.0.2.1"),
+		},
+	},
+	// a single CNAME for FQDN endpoints
+	{
+		Qname: "fqdn1.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.CNAME("fqdn1.testns.svc.cluster.local.	5	IN	CNAME	lb1.example.org."),
+			test.A("lb1.example.org.	5	IN	A	192.0.2.1"),
+		},
+	},
+	// FQDN endpoints can't be queried by name
+	{
+		Qname: "lb1-example-org.clusterid.fqdn1.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+}
+
+func TestExternalServeDNS(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &externalControllerMock{}
+	m.Upstream = upstreamMock{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	ctx := context.TODO()
+
+	for i, tc := range externalTestCases {
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := m.ServeDNS(ctx, w, tc.Msg()); err != nil {
+			t.Errorf("Test %d expected no error, got %v", i, err)
+			continue
+		}
+		if err := test.SortAndCheck(w.Msg, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+	}
+}
+
+func TestToServiceImportExternalName(t *testing.T) {
+	si := &mcs.ServiceImport{}
+	si.Name, si.Namespace = "ext1", "testns"
+	si.Annotations = map[string]string{object.ExternalNameAnnotation: "lb.example.org"}
+	o, err := object.ToServiceImport(si)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := o.(*object.ServiceImport).ExternalName; name != "lb.example.org." {
+		t.Errorf("Expected external name lb.example.org., got %q", name)
+	}
+}
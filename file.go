@@ -0,0 +1,321 @@
+package multicluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	k8sObject "github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/multicluster/object"
+	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/tools/cache"
+)
+
+// defaultFileReload is the default interval at which the manifest file is checked for changes.
+const defaultFileReload = 5 * time.Second
+
+// fileController serves ServiceImports and EndpointSlices read from a manifest file on disk.
+// It implements the controller interface, so findServices is unaware of where the data comes from.
+type fileController struct {
+	// modified tracks timestamp of the most recent changes
+	// It needs to be first because it is guaranteed to be 8-byte
+	// aligned ( we use sync.LoadAtomic with this )
+	modified int64
+
+	path   string
+	reload time.Duration
+	opts   controllerOpts
+
+	svcImportLister cache.Indexer
+	epLister        cache.Indexer
+	nsLister        cache.Store
+
+	// mtime and size are only read and modified by a single goroutine
+	mtime time.Time
+	size  int64
+
+	synced atomic.Bool
+
+	stopLock sync.Mutex
+	shutdown bool
+	stopCh   chan struct{}
+}
+
+func newFileController(path string, reload time.Duration, opts controllerOpts) (*fileController, error) {
+	if reload <= 0 {
+		reload = defaultFileReload
+	}
+	fc := &fileController{
+		path:            path,
+		reload:          reload,
+		opts:            opts,
+		svcImportLister: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{svcNameNamespaceIndex: svcNameNamespaceIndexFunc}),
+		epLister:        cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{epNameNamespaceIndex: epNameNamespaceIndexFunc}),
+		nsLister:        cache.NewStore(cache.MetaNamespaceKeyFunc),
+		stopCh:          make(chan struct{}),
+	}
+	// An unreadable or invalid file at startup is a configuration error.
+	if err := fc.load(); err != nil {
+		return nil, err
+	}
+	return fc, nil
+}
+
+// Run starts the controller and polls the file for changes until stopped.
+func (fc *fileController) Run() {
+	ticker := time.NewTicker(fc.reload)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fc.stopCh:
+			return
+		case <-ticker.C:
+			if err := fc.load(); err != nil {
+				log.Errorf("Failed to reload %s, keeping previous data: %v", fc.path, err)
+			}
+		}
+	}
+}
+
+// HasSynced returns true once the file has been read successfully.
+func (fc *fileController) HasSynced() bool { return fc.synced.Load() }
+
+// Stop stops the controller.
+func (fc *fileController) Stop() error {
+	fc.stopLock.Lock()
+	defer fc.stopLock.Unlock()
+
+	if !fc.shutdown {
+		close(fc.stopCh)
+		fc.shutdown = true
+
+		return nil
+	}
+
+	return fmt.Errorf("shutdown already in progress")
+}
+
+// load reads the file if its size or modification time changed since the last read.
+func (fc *fileController) load() error {
+	stat, err := os.Stat(fc.path)
+	if err != nil {
+		return err
+	}
+	if fc.mtime.Equal(stat.ModTime()) && fc.size == stat.Size() {
+		return nil
+	}
+
+	data, err := os.ReadFile(fc.path)
+	if err != nil {
+		return err
+	}
+	svcs, eps, nss, err := parseManifests(data, fc.opts.labelPolicy)
+	if err != nil {
+		return err
+	}
+	if !fc.opts.initEndpointsCache {
+		eps = nil
+	}
+
+	if err := fc.svcImportLister.Replace(svcs, stat.ModTime().String()); err != nil {
+		return err
+	}
+	if err := fc.epLister.Replace(eps, stat.ModTime().String()); err != nil {
+		return err
+	}
+	if err := fc.nsLister.Replace(nss, stat.ModTime().String()); err != nil {
+		return err
+	}
+
+	fc.mtime = stat.ModTime()
+	fc.size = stat.Size()
+	fc.synced.Store(true)
+	fc.updateModified()
+
+	log.Infof("Loaded %d ServiceImports and %d EndpointSlices from %s", len(svcs), len(eps), fc.path)
+	return nil
+}
+
+// parseManifests decodes the (multi-document) YAML or JSON in data and converts ServiceImports,
+// EndpointSlices and Namespaces to the objects held by the caches. A namespace is created for
+// every namespace an object lives in. Policy is applied to invalid cluster ids and hostnames.
+func parseManifests(data []byte, policy object.LabelPolicy) (svcs, eps, nss []interface{}, err error) {
+	namespaces := map[string]struct{}{}
+
+	var add func(raw json.RawMessage) error
+	add = func(raw json.RawMessage) error {
+		var tm meta.TypeMeta
+		if err := json.Unmarshal(raw, &tm); err != nil {
+			return err
+		}
+		switch tm.Kind {
+		case "List", "ServiceImportList", "EndpointSliceList", "NamespaceList":
+			var list struct {
+				Items []json.RawMessage `json:"items"`
+			}
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return err
+			}
+			for _, item := range list.Items {
+				if err := add(item); err != nil {
+					return err
+				}
+			}
+		case "ServiceImport":
+			// any supported version, see object.UnstructuredToServiceImport
+			si := &unstructured.Unstructured{}
+			if err := si.UnmarshalJSON(raw); err != nil {
+				return err
+			}
+			if si.GetName() == "" || si.GetNamespace() == "" {
+				return errors.New("ServiceImport must have a name and namespace")
+			}
+			namespaces[si.GetNamespace()] = struct{}{}
+			o, err := object.UnstructuredToServiceImport(si)
+			if err != nil {
+				return err
+			}
+			svcs = append(svcs, o)
+		case "EndpointSlice":
+			es := &discovery.EndpointSlice{}
+			if err := json.Unmarshal(raw, es); err != nil {
+				return err
+			}
+			if es.GetName() == "" || es.GetNamespace() == "" {
+				return errors.New("EndpointSlice must have a name and namespace")
+			}
+			namespaces[es.GetNamespace()] = struct{}{}
+			o, err := object.EndpointSliceConverter(policy)(es)
+			if err != nil {
+				return err
+			}
+			eps = append(eps, o)
+		case "Namespace":
+			ns := &api.Namespace{}
+			if err := json.Unmarshal(raw, ns); err != nil {
+				return err
+			}
+			namespaces[ns.GetName()] = struct{}{}
+		case "":
+			// empty document
+		default:
+			log.Warningf("Ignoring unsupported kind %q", tm.Kind)
+		}
+		return nil
+	}
+
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, nil, nil, err
+		}
+		if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+			continue
+		}
+		if err := add(raw); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	for name := range namespaces {
+		o, err := k8sObject.ToNamespace(&api.Namespace{ObjectMeta: meta.ObjectMeta{Name: name}})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		nss = append(nss, o)
+	}
+	return svcs, eps, nss, nil
+}
+
+func (fc *fileController) SvcIndex(idx string) (svcs []*object.ServiceImport) {
+	objs, err := fc.svcImportLister.ByIndex(svcNameNamespaceIndex, idx)
+	if err != nil {
+		return nil
+	}
+	for _, o := range objs {
+		s, ok := o.(*object.ServiceImport)
+		if !ok {
+			continue
+		}
+		svcs = append(svcs, s)
+	}
+	return svcs
+}
+
+func (fc *fileController) ServiceList() (svcs []*object.ServiceImport) {
+	for _, o := range fc.svcImportLister.List() {
+		s, ok := o.(*object.ServiceImport)
+		if !ok {
+			continue
+		}
+		svcs = append(svcs, s)
+	}
+	return svcs
+}
+
+func (fc *fileController) EndpointsList() (eps []*object.Endpoints) {
+	for _, o := range fc.epLister.List() {
+		ep, ok := o.(*object.Endpoints)
+		if !ok {
+			continue
+		}
+		eps = append(eps, ep)
+	}
+	return eps
+}
+
+func (fc *fileController) EpIndex(idx string) (ep []*object.Endpoints) {
+	objs, err := fc.epLister.ByIndex(epNameNamespaceIndex, idx)
+	if err != nil {
+		return nil
+	}
+	for _, o := range objs {
+		e, ok := o.(*object.Endpoints)
+		if !ok {
+			continue
+		}
+		ep = append(ep, e)
+	}
+	return ep
+}
+
+// NamespaceList returns the names of all namespaces.
+func (fc *fileController) NamespaceList() []string { return namespaceNames(fc.nsLister) }
+
+// GetNamespaceByName returns the namespace by name. If nothing is found an error is returned.
+func (fc *fileController) GetNamespaceByName(name string) (*k8sObject.Namespace, error) {
+	o, exists, err := fc.nsLister.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("namespace not found")
+	}
+	ns, ok := o.(*k8sObject.Namespace)
+	if !ok {
+		return nil, fmt.Errorf("found key but not namespace")
+	}
+	return ns, nil
+}
+
+func (fc *fileController) Modified() int64 {
+	return atomic.LoadInt64(&fc.modified)
+}
+
+func (fc *fileController) updateModified() {
+	atomic.StoreInt64(&fc.modified, time.Now().Unix())
+}
@@ -0,0 +1,174 @@
+package multicluster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+)
+
+const testManifest = `apiVersion: multicluster.x-k8s.io/v1alpha1
+kind: ServiceImport
+metadata:
+  name: svc1
+  namespace: testns
+spec:
+  type: ClusterSetIP
+  ips: ["10.0.0.1"]
+  ports:
+  - name: http
+    protocol: TCP
+    port: 80
+---
+apiVersion: multicluster.x-k8s.io/v1alpha1
+kind: ServiceImport
+metadata:
+  name: hdls1
+  namespace: testns
+spec:
+  type: Headless
+  ports:
+  - name: http
+    protocol: TCP
+    port: 80
+---
+apiVersion: discovery.k8s.io/v1
+kind: EndpointSlice
+metadata:
+  name: hdls1-c1
+  namespace: testns
+  labels:
+    multicluster.kubernetes.io/service-name: hdls1
+    multicluster.kubernetes.io/source-cluster: c1
+addressType: IPv4
+ports:
+- name: http
+  protocol: TCP
+  port: 80
+endpoints:
+- addresses: ["172.0.0.2"]
+- addresses: ["172.0.0.3"]
+  hostname: web-0
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: emptyns
+`
+
+func writeManifest(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "clusterset.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileController(t *testing.T) {
+	path := writeManifest(t, testManifest)
+	fc, err := newFileController(path, time.Second, controllerOpts{initEndpointsCache: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !fc.HasSynced() {
+		t.Error("Expected controller to be synced")
+	}
+	if n := len(fc.ServiceList()); n != 2 {
+		t.Errorf("Expected 2 ServiceImports, got %d", n)
+	}
+	eps := fc.EpIndex("hdls1.testns")
+	if len(eps) != 1 {
+		t.Fatalf("Expected 1 Endpoints, got %d", len(eps))
+	}
+	if eps[0].ClusterId != "c1" {
+		t.Errorf("Expected cluster id 'c1', got '%s'", eps[0].ClusterId)
+	}
+	for _, ns := range []string{"testns", "emptyns"} {
+		if _, err := fc.GetNamespaceByName(ns); err != nil {
+			t.Errorf("Expected namespace %s to exist, got %v", ns, err)
+		}
+	}
+	if _, err := fc.GetNamespaceByName("nsnoexist"); err == nil {
+		t.Error("Expected namespace nsnoexist to not exist")
+	}
+
+	// A broken file keeps the previous data.
+	if err := os.WriteFile(path, []byte("kind: ServiceImport\nmetadata: [\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.load(); err == nil {
+		t.Error("Expected error loading invalid manifest")
+	}
+	if n := len(fc.ServiceList()); n != 2 {
+		t.Errorf("Expected 2 ServiceImports after failed reload, got %d", n)
+	}
+}
+
+func TestFileControllerInvalid(t *testing.T) {
+	if _, err := newFileController(filepath.Join(t.TempDir(), "missing.yaml"), 0, controllerOpts{}); err == nil {
+		t.Error("Expected error for missing file")
+	}
+	path := writeManifest(t, "kind: ServiceImport\nmetadata:\n  name: noname\n")
+	if _, err := newFileController(path, 0, controllerOpts{}); err == nil {
+		t.Error("Expected error for ServiceImport without namespace")
+	}
+}
+
+var fileTestCases = []test.Case{
+	{
+		Qname: "svc1.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("svc1.testns.svc.cluster.local.	5	IN	A	10.0.0.1"),
+		},
+	},
+	{
+		Qname: "hdls1.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("hdls1.testns.svc.cluster.local.	5	IN	A	172.0.0.2"),
+			test.A("hdls1.testns.svc.cluster.local.	5	IN	A	172.0.0.3"),
+		},
+	},
+	{
+		Qname: "web-0.c1.hdls1.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("web-0.c1.hdls1.testns.svc.cluster.local.	5	IN	A	172.0.0.3"),
+		},
+	},
+}
+
+func TestFileServeDNS(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.file = writeManifest(t, testManifest)
+	m.opts.initEndpointsCache = true
+	if _, _, err := m.InitController(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	ctx := context.TODO()
+
+	for i, tc := range fileTestCases {
+		r := tc.Msg()
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+
+		if _, err := m.ServeDNS(ctx, w, r); err != nil {
+			t.Errorf("Test %d expected no error, got %v", i, err)
+			continue
+		}
+		resp := w.Msg
+		if resp == nil {
+			t.Fatalf("Test %d, got nil message and no error for %q", i, r.Question[0].Name)
+		}
+		if err := test.SortAndCheck(resp, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package multicluster
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/coredns/coredns/request"
+	"github.com/coredns/multicluster/object"
+	"github.com/miekg/dns"
+)
+
+// infoLabel is the first label of the TXT records describing a service: _info.service.namespace.svc.zone
+const infoLabel = "_info"
+
+// parseInfoName returns the service and namespace of an _info name.
+func parseInfoName(name, zone string) (service, namespace string, ok bool) {
+	base, _ := dnsutil.TrimZone(name, zone)
+	segs := dns.SplitDomainName(base)
+	if len(segs) != 4 || segs[0] != infoLabel || segs[3] != Svc {
+		return "", "", false
+	}
+	return segs[1], segs[2], true
+}
+
+// serviceInfo returns the TXT records describing the service namespace/name: its type, ports,
+// ClusterSetIPs or external name, and the clusters with their number of endpoints. Drained and
+// unhealthy clusters are marked as such.
+func (m *MultiCluster) serviceInfo(state request.Request, name, namespace string) ([]msg.Service, error) {
+	if !m.namespaceExists(namespace) {
+		return nil, errNoItems
+	}
+	idx := object.ServiceKey(name, namespace)
+	svcs := m.controller.SvcIndex(idx)
+	if len(svcs) == 0 {
+		return nil, errNoItems
+	}
+	if state.QType() != dns.TypeTXT {
+		return nil, nil
+	}
+
+	var text []string
+	for _, svc := range svcs {
+		text = append(text, "type="+string(svc.Type))
+		if len(svc.Ports) > 0 {
+			ports := make([]string, len(svc.Ports))
+			for i, p := range svc.Ports {
+				ports[i] = p.Name + "/" + strings.ToUpper(string(p.Protocol)) + ":" + strconv.Itoa(int(p.Port))
+			}
+			text = append(text, "ports="+strings.Join(ports, ","))
+		}
+		if len(svc.ClusterIPs) > 0 {
+			text = append(text, "ips="+strings.Join(svc.ClusterIPs, ","))
+		}
+		if svc.ExternalName != "" {
+			text = append(text, "external-name="+svc.ExternalName)
+		}
+
+		endpoints := map[string]map[string]struct{}{}
+		for _, c := range svc.Clusters {
+			endpoints[c] = map[string]struct{}{}
+		}
+		for _, ep := range m.controller.EpIndex(idx) {
+			if ep.Index != object.EndpointsKey(svc.Name, svc.Namespace) || !svc.ExportedBy(ep.ClusterId) {
+				continue
+			}
+			if endpoints[ep.ClusterId] == nil {
+				endpoints[ep.ClusterId] = map[string]struct{}{}
+			}
+			for _, sub := range ep.Subsets {
+				for _, addr := range sub.Addresses {
+					endpoints[ep.ClusterId][addr.IP] = struct{}{}
+				}
+			}
+		}
+		clusters := make([]string, 0, len(endpoints))
+		for c := range endpoints {
+			clusters = append(clusters, c)
+		}
+		sort.Strings(clusters)
+		for _, c := range clusters {
+			t := "cluster=" + m.aliases.label(c) + " endpoints=" + strconv.Itoa(len(endpoints[c]))
+			if m.drain.drained(c) {
+				t += " drained"
+			}
+			if m.health.unhealthy(c) {
+				t += " unhealthy"
+			}
+			text = append(text, t)
+		}
+	}
+
+	services := make([]msg.Service, len(text))
+	for i, t := range text {
+		services[i] = msg.Service{Text: t, TTL: m.ttl, Key: msg.Path(state.QName(), coredns) + "/" + strconv.Itoa(i)}
+	}
+	return services, nil
+}
@@ -0,0 +1,103 @@
+package multicluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+)
+
+var infoTestCases = []test.Case{
+	{
+		Qname: "_info.svc1.testns.svc.cluster.local.", Qtype: dns.TypeTXT,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.TXT(`_info.svc1.testns.svc.cluster.local.	5	IN	TXT	"cluster=clusterid endpoints=1 drained"`),
+			test.TXT("_info.svc1.testns.svc.cluster.local.	5	IN	TXT	ips=10.0.0.1"),
+			test.TXT("_info.svc1.testns.svc.cluster.local.	5	IN	TXT	ports=http/TCP:80"),
+			test.TXT("_info.svc1.testns.svc.cluster.local.	5	IN	TXT	type=ClusterSetIP"),
+		},
+	},
+	{
+		Qname: "_info.hdls1.testns.svc.cluster.local.", Qtype: dns.TypeTXT,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.TXT(`_info.hdls1.testns.svc.cluster.local.	5	IN	TXT	"cluster=clusterid endpoints=6 drained"`),
+			test.TXT("_info.hdls1.testns.svc.cluster.local.	5	IN	TXT	type=Headless"),
+		},
+	},
+	// NODATA for other types
+	{
+		Qname: "_info.svc1.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+	// NXDOMAIN for unknown services
+	{
+		Qname: "_info.nosvc.testns.svc.cluster.local.", Qtype: dns.TypeTXT,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+}
+
+func TestServiceInfoServeDNS(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	m.drain.static["clusterid"] = struct{}{}
+	m.info = true
+	ctx := context.TODO()
+
+	for i, tc := range infoTestCases {
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := m.ServeDNS(ctx, w, tc.Msg()); err != nil {
+			t.Errorf("Test %d expected no error, got %v", i, err)
+			continue
+		}
+		if err := test.SortAndCheck(w.Msg, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+	}
+}
+
+func TestParseInfoName(t *testing.T) {
+	if name, namespace, ok := parseInfoName("_info.svc1.testns.svc.cluster.local.", "cluster.local."); !ok || name != "svc1" || namespace != "testns" {
+		t.Errorf("Expected svc1 in testns, got %q in %q", name, namespace)
+	}
+	if _, _, ok := parseInfoName("_info.testns.svc.cluster.local.", "cluster.local."); ok {
+		t.Error("Expected no service for a name without one")
+	}
+}
+
+func TestServiceInfoAlias(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	m.aliases.add("clusterid", "east")
+	m.info = true
+
+	tc := test.Case{Qname: "_info.svc1.testns.svc.cluster.local.", Qtype: dns.TypeTXT}
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := m.ServeDNS(context.TODO(), w, tc.Msg()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	found := false
+	for _, rr := range w.Msg.Answer {
+		if txt, ok := rr.(*dns.TXT); ok && strings.HasPrefix(txt.Txt[0], "cluster=") {
+			found = true
+			if txt.Txt[0] != "cluster=east endpoints=1" {
+				t.Errorf("Expected the cluster to be named by its alias, got %q", txt.Txt[0])
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected a cluster record")
+	}
+}
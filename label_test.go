@@ -0,0 +1,185 @@
+package multicluster
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	k8sObject "github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/multicluster/object"
+	"github.com/miekg/dns"
+	discovery "k8s.io/api/discovery/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+func TestSanitizeLabel(t *testing.T) {
+	long := strings.Repeat("a", 70)
+	tests := []struct {
+		in, out string
+	}{
+		{"cluster-1", "cluster-1"},
+		{"Cluster_1", "cluster-1"},
+		{"-east.zone-", "east-zone"},
+		{long, strings.Repeat("a", 54) + "-" + object.SanitizeLabel(long)[55:]},
+	}
+	for i, tc := range tests {
+		out := object.SanitizeLabel(tc.in)
+		if out != tc.out {
+			t.Errorf("Test %d: expected %q, got %q", i, tc.out, out)
+		}
+		if !object.ValidLabel(out) {
+			t.Errorf("Test %d: expected %q to be a valid label", i, out)
+		}
+	}
+	if object.SanitizeLabel(long) == object.SanitizeLabel(long+"b") {
+		t.Error("Expected truncated labels to stay distinct")
+	}
+	if out := object.SanitizeLabel("___"); !object.ValidLabel(out) {
+		t.Errorf("Expected a valid label for %q, got %q", "___", out)
+	}
+}
+
+func labelTestSlice(cluster string, hostnames ...string) *discovery.EndpointSlice {
+	es := &discovery.EndpointSlice{ObjectMeta: meta.ObjectMeta{
+		Name:      "hdls1-slice1",
+		Namespace: "testns",
+		Labels:    map[string]string{mcs.LabelServiceName: "hdls1", mcs.LabelSourceCluster: cluster},
+	}}
+	for i, h := range hostnames {
+		h := h
+		es.Endpoints = append(es.Endpoints, discovery.Endpoint{
+			Addresses: []string{"10.0.0." + string(rune('1'+i))},
+			Hostname:  &h,
+		})
+	}
+	return es
+}
+
+func convertedHostnames(t *testing.T, es *discovery.EndpointSlice, policy object.LabelPolicy) (string, []string) {
+	t.Helper()
+	o, err := object.EndpointSliceConverter(policy)(es)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep := o.(*object.Endpoints)
+	var hostnames []string
+	for _, addr := range ep.Subsets[0].Addresses {
+		hostnames = append(hostnames, addr.IP+"="+addr.Hostname)
+	}
+	if len(hostnames) != len(ep.IndexIP) {
+		t.Errorf("Expected IndexIP to match the addresses, got %v", ep.IndexIP)
+	}
+	return ep.ClusterId, hostnames
+}
+
+func TestEndpointSliceLabelPolicy(t *testing.T) {
+	tests := []struct {
+		policy    object.LabelPolicy
+		slice     *discovery.EndpointSlice
+		cluster   string
+		hostnames string
+	}{
+		{object.LabelSanitize, labelTestSlice("c1", "web-0", "web-1"), "c1", "10.0.0.1=web-0,10.0.0.2=web-1"},
+		{object.LabelSanitize, labelTestSlice("East_1", "Web_0"), "east-1", "10.0.0.1=web-0"},
+		// the colliding endpoint is served under its address based name
+		{object.LabelSanitize, labelTestSlice("c1", "web-0", "Web_0", "web-0"), "c1", "10.0.0.1=web-0,10.0.0.2=,10.0.0.3="},
+		{object.LabelReject, labelTestSlice("c1", "web-0", "Web_0", "web-0", "web-1"), "c1", "10.0.0.1=web-0,10.0.0.4=web-1"},
+		{object.LabelReject, labelTestSlice("East_1", "web-0"), "East_1", ""},
+	}
+	for i, tc := range tests {
+		cluster, hostnames := convertedHostnames(t, tc.slice, tc.policy)
+		if cluster != tc.cluster || strings.Join(hostnames, ",") != tc.hostnames {
+			t.Errorf("Test %d: expected %s %s, got %s %s", i, tc.cluster, tc.hostnames, cluster, strings.Join(hostnames, ","))
+		}
+	}
+}
+
+// hostnameControllerMock adds a headless service whose endpoints of cluster c1 are spread over two
+// EndpointSlices with a colliding hostname to controllerMock2.
+type hostnameControllerMock struct {
+	controllerMock2
+}
+
+func hostnameSlice(name, cluster string, addrs ...k8sObject.EndpointAddress) *object.Endpoints {
+	return &object.Endpoints{
+		Endpoints: k8sObject.Endpoints{
+			Subsets: []k8sObject.EndpointSubset{{
+				Addresses: addrs,
+				Ports:     []k8sObject.EndpointPort{{Port: 80, Protocol: "tcp", Name: "http"}},
+			}},
+			Name:      name,
+			Namespace: "testns",
+			Index:     object.EndpointsKey("hdlsdup", "testns"),
+		},
+		ClusterId: cluster,
+	}
+}
+
+func (c hostnameControllerMock) SvcIndex(s string) []*object.ServiceImport {
+	if s == "hdlsdup.testns" {
+		return []*object.ServiceImport{{Name: "hdlsdup", Namespace: "testns", Index: s, Type: mcs.Headless}}
+	}
+	return c.controllerMock2.SvcIndex(s)
+}
+
+func (c hostnameControllerMock) EpIndex(s string) []*object.Endpoints {
+	if s == "hdlsdup.testns" {
+		// the slice listed first doesn't own the hostname
+		return []*object.Endpoints{
+			hostnameSlice("hdlsdup-b", "c1", k8sObject.EndpointAddress{IP: "10.0.0.2", Hostname: "web-0"}),
+			hostnameSlice("hdlsdup-a", "c1", k8sObject.EndpointAddress{IP: "10.0.0.1", Hostname: "web-0"}),
+			hostnameSlice("hdlsdup-c", "c2", k8sObject.EndpointAddress{IP: "10.0.0.3", Hostname: "web-0"}),
+		}
+	}
+	return c.controllerMock2.EpIndex(s)
+}
+
+func TestHostnameCollisionsAcrossSlices(t *testing.T) {
+	resolve := func(m *MultiCluster, qname string) (int, []string) {
+		t.Helper()
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := m.ServeDNS(context.TODO(), w, new(dns.Msg).SetQuestion(qname, dns.TypeA)); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		var ips []string
+		for _, rr := range w.Msg.Answer {
+			ips = append(ips, rr.(*dns.A).A.String())
+		}
+		sort.Strings(ips)
+		return w.Msg.Rcode, ips
+	}
+
+	for _, policy := range []object.LabelPolicy{object.LabelSanitize, object.LabelReject} {
+		m := New([]string{"cluster.local."})
+		m.controller = &hostnameControllerMock{}
+		m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+		m.opts.labelPolicy = policy
+
+		// the slice with the lowest name owns the hostname, other clusters have their own
+		if _, ips := resolve(m, "web-0.c1.hdlsdup.testns.svc.cluster.local."); strings.Join(ips, ",") != "10.0.0.1" {
+			t.Errorf("Policy %d: expected web-0 of c1 to be 10.0.0.1, got %v", policy, ips)
+		}
+		if _, ips := resolve(m, "web-0.c2.hdlsdup.testns.svc.cluster.local."); strings.Join(ips, ",") != "10.0.0.3" {
+			t.Errorf("Policy %d: expected web-0 of c2 to be 10.0.0.3, got %v", policy, ips)
+		}
+
+		rcode, ips := resolve(m, "10-0-0-2.c1.hdlsdup.testns.svc.cluster.local.")
+		_, all := resolve(m, "hdlsdup.testns.svc.cluster.local.")
+		switch policy {
+		case object.LabelSanitize:
+			// the other endpoint is served under its address
+			if strings.Join(ips, ",") != "10.0.0.2" || len(all) != 3 {
+				t.Errorf("Expected 10.0.0.2 to be served under its address, got %v and %v", ips, all)
+			}
+		case object.LabelReject:
+			// or dropped
+			if rcode != dns.RcodeNameError || strings.Join(all, ",") != "10.0.0.1,10.0.0.3" {
+				t.Errorf("Expected 10.0.0.2 to be dropped, got %s and %v", dns.RcodeToString[rcode], all)
+			}
+		}
+	}
+}
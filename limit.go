@@ -0,0 +1,66 @@
+package multicluster
+
+import (
+	"math/rand"
+)
+
+// limitCandidates caps the number of endpoints in a headless answer to m.maxAnswers, keeping
+// answers small enough for UDP. Endpoints are sampled at random, so consecutive queries spread
+// over all endpoints. If m.maxAnswersBalanced is set, the sample is spread evenly over the
+// clusters. All ports of a sampled endpoint are kept.
+func (m *MultiCluster) limitCandidates(candidates []candidate) []candidate {
+	if m.maxAnswers <= 0 {
+		return candidates
+	}
+
+	// group the candidates by endpoint, keeping their order
+	type endpoint struct {
+		cluster    string
+		candidates []candidate
+	}
+	var endpoints []*endpoint
+	byKey := map[string]*endpoint{}
+	for _, c := range candidates {
+		key := c.cluster + "/" + c.addr.IP
+		e, ok := byKey[key]
+		if !ok {
+			e = &endpoint{cluster: c.cluster}
+			byKey[key] = e
+			endpoints = append(endpoints, e)
+		}
+		e.candidates = append(e.candidates, c)
+	}
+	if len(endpoints) <= m.maxAnswers {
+		return candidates
+	}
+
+	rand.Shuffle(len(endpoints), func(i, j int) { endpoints[i], endpoints[j] = endpoints[j], endpoints[i] })
+
+	sample := endpoints[:m.maxAnswers]
+	if m.maxAnswersBalanced {
+		// take the shuffled endpoints of each cluster in turn, starting at a random cluster
+		byCluster := map[string][]*endpoint{}
+		for _, e := range endpoints {
+			byCluster[e.cluster] = append(byCluster[e.cluster], e)
+		}
+		clusters := candidateClusters(candidates)
+		start := rand.Intn(len(clusters))
+		sample = make([]*endpoint, 0, m.maxAnswers)
+		for len(sample) < m.maxAnswers {
+			for i := range clusters {
+				c := clusters[(start+i)%len(clusters)]
+				if len(byCluster[c]) == 0 || len(sample) == m.maxAnswers {
+					continue
+				}
+				sample = append(sample, byCluster[c][0])
+				byCluster[c] = byCluster[c][1:]
+			}
+		}
+	}
+
+	selected := candidates[:0:0]
+	for _, e := range sample {
+		selected = append(selected, e.candidates...)
+	}
+	return selected
+}
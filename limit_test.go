@@ -0,0 +1,80 @@
+package multicluster
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	k8sObject "github.com/coredns/coredns/plugin/kubernetes/object"
+)
+
+// limitTestCandidates returns n endpoints with two ports each in cluster a, and one endpoint in cluster b.
+func limitTestCandidates(n int) []candidate {
+	var candidates []candidate
+	for i := 0; i < n; i++ {
+		ip := fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+		for _, port := range []int{80, 443} {
+			candidates = append(candidates, candidate{
+				cluster: "a",
+				addr:    k8sObject.EndpointAddress{IP: ip},
+				service: msg.Service{Host: ip, Port: port},
+			})
+		}
+	}
+	return append(candidates, candidate{
+		cluster: "b",
+		addr:    k8sObject.EndpointAddress{IP: "10.1.0.1"},
+		service: msg.Service{Host: "10.1.0.1", Port: 80},
+	})
+}
+
+func TestLimitCandidates(t *testing.T) {
+	m := New([]string{"cluster.local."})
+
+	if selected := m.limitCandidates(limitTestCandidates(100)); len(selected) != 201 {
+		t.Errorf("Expected all candidates without max_answers, got %d", len(selected))
+	}
+
+	m.maxAnswers = 10
+	if selected := m.limitCandidates(limitTestCandidates(5)); len(selected) != 11 {
+		t.Errorf("Expected all candidates below max_answers, got %d", len(selected))
+	}
+
+	endpoints := func(selected []candidate) map[string]int {
+		ports := map[string]int{}
+		for _, c := range selected {
+			ports[c.addr.IP]++
+		}
+		return ports
+	}
+
+	seenB := 0
+	for i := 0; i < 100; i++ {
+		eps := endpoints(m.limitCandidates(limitTestCandidates(100)))
+		if len(eps) != 10 {
+			t.Fatalf("Expected 10 endpoints, got %d", len(eps))
+		}
+		for ip, n := range eps {
+			if ip != "10.1.0.1" && n != 2 {
+				t.Fatalf("Expected both ports of %s, got %d", ip, n)
+			}
+		}
+		if _, ok := eps["10.1.0.1"]; ok {
+			seenB++
+		}
+	}
+	if seenB == 100 {
+		t.Error("Expected cluster b to not always be sampled without balancing")
+	}
+
+	m.maxAnswersBalanced = true
+	for i := 0; i < 100; i++ {
+		eps := endpoints(m.limitCandidates(limitTestCandidates(100)))
+		if len(eps) != 10 {
+			t.Fatalf("Expected 10 endpoints, got %d", len(eps))
+		}
+		if _, ok := eps["10.1.0.1"]; !ok {
+			t.Fatal("Expected cluster b to always be sampled when balanced")
+		}
+	}
+}
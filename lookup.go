@@ -0,0 +1,106 @@
+package multicluster
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+const (
+	// maxLookupDepth bounds the CNAME chains followed across the zones of the plugin.
+	maxLookupDepth        = 8
+	defaultForwardTimeout = 2 * time.Second
+)
+
+var errLookupLoop = errors.New("lookup depth exceeded")
+
+type lookupDepthKey struct{}
+
+// lookupLocal resolves name, which is in zone, from the cache as ServeDNS would. CNAME targets in
+// other zones of the plugin lead back here, so the depth of these lookups is bounded.
+func (m MultiCluster) lookupLocal(ctx context.Context, state request.Request, zone, name string, typ uint16) (*dns.Msg, error) {
+	depth, _ := ctx.Value(lookupDepthKey{}).(int)
+	if depth >= maxLookupDepth {
+		return nil, errLookupLoop
+	}
+	ctx = context.WithValue(ctx, lookupDepthKey{}, depth+1)
+
+	req := state.NewWithQuestion(name, typ)
+	req.Zone = zone
+
+	var (
+		records []dns.RR
+		extra   []dns.RR
+		err     error
+	)
+	switch typ {
+	case dns.TypeA:
+		records, _, err = plugin.A(ctx, &m, zone, req, nil, plugin.Options{})
+	case dns.TypeAAAA:
+		records, _, err = plugin.AAAA(ctx, &m, zone, req, nil, plugin.Options{})
+	case dns.TypeTXT:
+		records, _, err = plugin.TXT(ctx, &m, zone, req, nil, plugin.Options{})
+	case dns.TypeSRV:
+		records, extra, err = plugin.SRV(ctx, &m, zone, req, plugin.Options{})
+	}
+
+	reply := new(dns.Msg)
+	reply.SetQuestion(name, typ)
+	reply.Authoritative = true
+	switch {
+	case m.IsNameError(err):
+		reply.Rcode = dns.RcodeNameError
+	case err != nil:
+		return nil, err
+	}
+	reply.Answer = records
+	reply.Extra = extra
+	return reply, nil
+}
+
+// forwarder resolves names by sending the queries to a fixed list of nameservers, trying them in order.
+type forwarder struct {
+	addrs   []string
+	timeout time.Duration
+}
+
+func newForwarder(addrs []string) *forwarder {
+	return &forwarder{addrs: addrs, timeout: defaultForwardTimeout}
+}
+
+// Lookup implements the lookuper interface.
+func (f *forwarder) Lookup(ctx context.Context, state request.Request, name string, typ uint16) (*dns.Msg, error) {
+	req := new(dns.Msg)
+	req.SetQuestion(name, typ)
+	req.RecursionDesired = true
+
+	var err error
+	for _, addr := range f.addrs {
+		var reply *dns.Msg
+		if reply, err = f.exchange(ctx, req, addr); err == nil {
+			return reply, nil
+		}
+	}
+	return nil, err
+}
+
+// exchange sends req to addr over UDP, retrying over TCP if the reply is truncated.
+func (f *forwarder) exchange(ctx context.Context, req *dns.Msg, addr string) (*dns.Msg, error) {
+	c := &dns.Client{Net: "udp", Timeout: f.timeout}
+	reply, _, err := c.ExchangeContext(ctx, req, addr)
+	if err == nil && reply.Truncated {
+		c.Net = "tcp"
+		reply, _, err = c.ExchangeContext(ctx, req, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reply.Rcode == dns.RcodeServerFailure || reply.Rcode == dns.RcodeRefused {
+		return nil, errors.New("upstream " + addr + " returned " + dns.RcodeToString[reply.Rcode])
+	}
+	return reply, nil
+}
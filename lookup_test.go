@@ -0,0 +1,111 @@
+package multicluster
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// failingUpstream fails every lookup.
+type failingUpstream struct{}
+
+func (failingUpstream) Lookup(ctx context.Context, state request.Request, name string, typ uint16) (*dns.Msg, error) {
+	return nil, errors.New("unexpected upstream lookup")
+}
+
+func TestLookupLocal(t *testing.T) {
+	m := New([]string{"cluster.local.", "example.local."})
+	m.controller = &externalControllerMock{}
+	m.Upstream = failingUpstream{}
+	state := request.Request{W: &test.ResponseWriter{}, Req: new(dns.Msg).SetQuestion("ext2.testns.svc.cluster.local.", dns.TypeA)}
+
+	reply, err := m.Lookup(context.TODO(), state, "svc1.testns.svc.example.local.", dns.TypeA)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(reply.Answer) != 1 || reply.Answer[0].(*dns.A).A.String() != "10.0.0.1" {
+		t.Errorf("Expected the ClusterSetIP of svc1, got %v", reply.Answer)
+	}
+
+	reply, err = m.Lookup(context.TODO(), state, "nosvc.testns.svc.example.local.", dns.TypeA)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if reply.Rcode != dns.RcodeNameError {
+		t.Errorf("Expected NXDOMAIN, got %s", dns.RcodeToString[reply.Rcode])
+	}
+
+	ctx := context.WithValue(context.TODO(), lookupDepthKey{}, maxLookupDepth)
+	if _, err := m.Lookup(ctx, state, "svc1.testns.svc.example.local.", dns.TypeA); err != errLookupLoop {
+		t.Errorf("Expected %v, got %v", errLookupLoop, err)
+	}
+}
+
+func TestLookupCrossZoneServeDNS(t *testing.T) {
+	m := New([]string{"cluster.local.", "example.local."})
+	m.controller = &externalControllerMock{}
+	m.Upstream = failingUpstream{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+
+	tc := test.Case{
+		Qname: "ext2.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.CNAME("ext2.testns.svc.cluster.local.	5	IN	CNAME	svc1.testns.svc.example.local."),
+			test.A("svc1.testns.svc.example.local.	5	IN	A	10.0.0.1"),
+		},
+	}
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := m.ServeDNS(context.TODO(), w, tc.Msg()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := test.SortAndCheck(w.Msg, tc); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestForwarder(t *testing.T) {
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		reply := new(dns.Msg)
+		reply.SetReply(r)
+		switch {
+		case r.Question[0].Name == "refused.example.org.":
+			reply.Rcode = dns.RcodeRefused
+		case w.LocalAddr().Network() == "udp":
+			reply.Truncated = true
+		default:
+			reply.Answer = []dns.RR{test.A(r.Question[0].Name + "	5	IN	A	192.0.2.1")}
+		}
+		w.WriteMsg(reply)
+	})
+	defer s.Close()
+
+	// an address nothing listens on, so the forwarder has to move on to the next one
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.LocalAddr().String()
+	l.Close()
+
+	f := newForwarder([]string{closed, s.Addr})
+	state := request.Request{W: &test.ResponseWriter{}, Req: new(dns.Msg)}
+
+	reply, err := f.Lookup(context.TODO(), state, "lb.example.org.", dns.TypeA)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(reply.Answer) != 1 {
+		t.Errorf("Expected the answer to be retried over TCP, got %v", reply)
+	}
+
+	if _, err := f.Lookup(context.TODO(), state, "refused.example.org.", dns.TypeA); err == nil {
+		t.Error("Expected an error for a refused query")
+	}
+}
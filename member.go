@@ -0,0 +1,483 @@
+package multicluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	k8sObject "github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/multicluster/object"
+	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+	mcsClientset "sigs.k8s.io/mcs-api/pkg/client/clientset/versioned/typed/apis/v1alpha1"
+)
+
+// memberConfig is a member cluster of the clusterset as configured with the member directive.
+type memberConfig struct {
+	clusterID    string
+	clientConfig clientcmd.ClientConfig
+}
+
+// memberCluster holds the caches of a single member cluster.
+type memberCluster struct {
+	clusterID string
+
+	k8sClient kubernetes.Interface
+	mcsClient mcsClientset.MulticlusterV1alpha1Interface
+
+	exportController cache.Controller
+	exportLister     cache.Indexer
+
+	svcController cache.Controller
+	svcLister     cache.Indexer
+
+	epController cache.Controller
+	epLister     cache.Indexer
+
+	nsController cache.Controller
+	nsLister     cache.Store
+}
+
+// memberController watches ServiceExports, Services and EndpointSlices in every member cluster
+// and derives the ServiceImports from them, without an external MCS controller.
+type memberController struct {
+	// modified tracks timestamp of the most recent changes
+	// It needs to be first because it is guaranteed to be 8-byte
+	// aligned ( we use sync.LoadAtomic with this )
+	modified int64
+
+	members []*memberCluster
+
+	// clusterSetIPs maps a service index to its configured ClusterSetIPs.
+	clusterSetIPs map[string][]string
+
+	conflicts *conflictTracker
+
+	stopLock sync.Mutex
+	shutdown bool
+	stopCh   chan struct{}
+}
+
+func newMemberController(ctx context.Context, members []*memberCluster, clusterSetIPs map[string][]string, opts controllerOpts) *memberController {
+	mc := &memberController{
+		members:       members,
+		clusterSetIPs: clusterSetIPs,
+		conflicts:     newConflictTracker(),
+		stopCh:        make(chan struct{}),
+	}
+	if opts.conflictEvents {
+		mc.conflicts.onConflict = mc.conflictEvent
+	}
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { mc.updateModified(); mc.checkConflicts(obj) },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			mc.detectChanges(oldObj, newObj)
+			mc.checkConflicts(newObj)
+		},
+		DeleteFunc: func(obj interface{}) { mc.updateModified(); mc.checkConflicts(obj) },
+	}
+	for _, m := range members {
+		m.watch(ctx, handler, opts)
+	}
+	return mc
+}
+
+func (m *memberCluster) watch(ctx context.Context, h cache.ResourceEventHandler, opts controllerOpts) {
+	m.exportLister, m.exportController = cache.NewIndexerInformer(
+		&cache.ListWatch{
+			ListFunc: func(o meta.ListOptions) (runtime.Object, error) {
+				return m.mcsClient.ServiceExports(api.NamespaceAll).List(ctx, o)
+			},
+			WatchFunc: func(o meta.ListOptions) (watch.Interface, error) {
+				return m.mcsClient.ServiceExports(api.NamespaceAll).Watch(ctx, o)
+			},
+		},
+		&mcs.ServiceExport{},
+		0,
+		h,
+		cache.Indexers{},
+	)
+
+	m.svcLister, m.svcController = k8sObject.NewIndexerInformer(
+		&cache.ListWatch{
+			ListFunc: func(o meta.ListOptions) (runtime.Object, error) {
+				return m.k8sClient.CoreV1().Services(api.NamespaceAll).List(ctx, o)
+			},
+			WatchFunc: func(o meta.ListOptions) (watch.Interface, error) {
+				return m.k8sClient.CoreV1().Services(api.NamespaceAll).Watch(ctx, o)
+			},
+		},
+		&api.Service{},
+		h,
+		cache.Indexers{},
+		k8sObject.DefaultProcessor(k8sObject.ToService, nil),
+	)
+
+	m.nsLister, m.nsController = k8sObject.NewIndexerInformer(
+		&cache.ListWatch{
+			ListFunc:  namespaceListFunc(ctx, m.k8sClient),
+			WatchFunc: namespaceWatchFunc(ctx, m.k8sClient),
+		},
+		&api.Namespace{},
+		cache.ResourceEventHandlerFuncs{},
+		cache.Indexers{},
+		k8sObject.DefaultProcessor(k8sObject.ToNamespace, nil),
+	)
+
+	if opts.initEndpointsCache {
+		m.epLister, m.epController = k8sObject.NewIndexerInformer(
+			&cache.ListWatch{
+				ListFunc: func(o meta.ListOptions) (runtime.Object, error) {
+					o.LabelSelector = discovery.LabelServiceName
+					return m.k8sClient.DiscoveryV1().EndpointSlices(api.NamespaceAll).List(ctx, o)
+				},
+				WatchFunc: func(o meta.ListOptions) (watch.Interface, error) {
+					o.LabelSelector = discovery.LabelServiceName
+					return m.k8sClient.DiscoveryV1().EndpointSlices(api.NamespaceAll).Watch(ctx, o)
+				},
+			},
+			&discovery.EndpointSlice{},
+			h,
+			cache.Indexers{epNameNamespaceIndex: epNameNamespaceIndexFunc},
+			k8sObject.DefaultProcessor(m.endpointsConverter(opts.labelPolicy), nil),
+		)
+	}
+}
+
+// endpointsConverter returns a function labeling a member's own EndpointSlice the way an MCS
+// controller would, and converting it.
+func (m *memberCluster) endpointsConverter(policy object.LabelPolicy) k8sObject.ToFunc {
+	convert := object.EndpointSliceConverter(policy)
+	return func(obj meta.Object) (meta.Object, error) {
+		labels := make(map[string]string, len(obj.GetLabels())+2)
+		for k, v := range obj.GetLabels() {
+			labels[k] = v
+		}
+		labels[mcs.LabelServiceName] = labels[discovery.LabelServiceName]
+		labels[mcs.LabelSourceCluster] = m.clusterID
+		obj.SetLabels(labels)
+		return convert(obj)
+	}
+}
+
+// export returns the ServiceExport name/namespace in this member, or nil if there is none.
+func (m *memberCluster) export(name, namespace string) *mcs.ServiceExport {
+	o, exists, err := m.exportLister.GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return nil
+	}
+	se, _ := o.(*mcs.ServiceExport)
+	return se
+}
+
+// exported returns the Service backing the ServiceExport name/namespace in this member,
+// or nil if the service isn't exported from this member.
+func (m *memberCluster) exported(name, namespace string) *k8sObject.Service {
+	if m.export(name, namespace) == nil {
+		return nil
+	}
+	o, exists, err := m.svcLister.GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return nil
+	}
+	svc, ok := o.(*k8sObject.Service)
+	if !ok || svc.Type == api.ServiceTypeExternalName {
+		return nil
+	}
+	return svc
+}
+
+// Stop stops the controller.
+func (mc *memberController) Stop() error {
+	mc.stopLock.Lock()
+	defer mc.stopLock.Unlock()
+
+	if !mc.shutdown {
+		close(mc.stopCh)
+		mc.shutdown = true
+
+		return nil
+	}
+
+	return fmt.Errorf("shutdown already in progress")
+}
+
+// Run starts the controller.
+func (mc *memberController) Run() {
+	for _, m := range mc.members {
+		go m.exportController.Run(mc.stopCh)
+		go m.svcController.Run(mc.stopCh)
+		go m.nsController.Run(mc.stopCh)
+		if m.epController != nil {
+			go m.epController.Run(mc.stopCh)
+		}
+	}
+
+	<-mc.stopCh
+}
+
+// HasSynced calls on all controllers of all members.
+func (mc *memberController) HasSynced() bool {
+	for _, m := range mc.members {
+		if !m.exportController.HasSynced() || !m.svcController.HasSynced() || !m.nsController.HasSynced() {
+			return false
+		}
+		if m.epController != nil && !m.epController.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// SvcIndex returns the ServiceImport derived from the ServiceExports matching idx.
+func (mc *memberController) SvcIndex(idx string) []*object.ServiceImport {
+	name, namespace, ok := strings.Cut(idx, ".")
+	if !ok {
+		return nil
+	}
+	if s := mc.serviceImport(name, namespace); s != nil {
+		return []*object.ServiceImport{s}
+	}
+	return nil
+}
+
+// ServiceList returns the ServiceImports derived from all ServiceExports in all members.
+func (mc *memberController) ServiceList() (svcs []*object.ServiceImport) {
+	seen := map[string]struct{}{}
+	for _, m := range mc.members {
+		for _, key := range m.exportLister.ListKeys() {
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			namespace, name, err := cache.SplitMetaNamespaceKey(key)
+			if err != nil {
+				continue
+			}
+			if s := mc.serviceImport(name, namespace); s != nil {
+				svcs = append(svcs, s)
+			}
+		}
+	}
+	return svcs
+}
+
+// serviceImport derives the ServiceImport for name/namespace from the Services exported by the members.
+// Services get the ClusterSetIPs configured for them, a service without any is served as headless.
+func (mc *memberController) serviceImport(name, namespace string) *object.ServiceImport {
+	var (
+		versions []string
+		clusters []string
+		ports    []mcs.ServicePort
+	)
+	seenPort := map[mcs.ServicePort]struct{}{}
+	for _, m := range mc.members {
+		svc := mc.exported(m, name, namespace)
+		if svc == nil {
+			continue
+		}
+		versions = append(versions, m.clusterID+"="+svc.Version)
+		clusters = append(clusters, m.clusterID)
+		for _, p := range svc.Ports {
+			if p.Port == -1 {
+				continue // sentinel for a portless service
+			}
+			sp := mcs.ServicePort{Name: p.Name, Protocol: p.Protocol, Port: p.Port}
+			if _, ok := seenPort[sp]; ok {
+				continue
+			}
+			seenPort[sp] = struct{}{}
+			sp.AppProtocol = p.AppProtocol
+			ports = append(ports, sp)
+		}
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+
+	s := &object.ServiceImport{
+		Version:   strings.Join(versions, ","),
+		Name:      name,
+		Namespace: namespace,
+		Index:     object.ServiceKey(name, namespace),
+		Type:      mcs.Headless,
+		Ports:     ports,
+		Clusters:  clusters,
+	}
+	if ips := mc.clusterSetIPs[s.Index]; len(ips) > 0 {
+		s.Type = mcs.ClusterSetIP
+		s.ClusterIPs = make([]string, len(ips))
+		copy(s.ClusterIPs, ips)
+	}
+	return s
+}
+
+// exported returns the Service exported by member m, or nil if m doesn't export it or its
+// export lost a conflict.
+func (mc *memberController) exported(m *memberCluster, name, namespace string) *k8sObject.Service {
+	if mc.conflicts.lost(object.ServiceKey(name, namespace), m.clusterID) {
+		return nil
+	}
+	return m.exported(name, namespace)
+}
+
+// checkConflicts detects conflicts between the members exporting the service obj belongs to.
+func (mc *memberController) checkConflicts(obj interface{}) {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+	var name, namespace string
+	switch o := obj.(type) {
+	case *object.Endpoints:
+		name, _, _ = strings.Cut(o.Index, ".")
+		namespace = o.GetNamespace()
+	case meta.Object:
+		name, namespace = o.GetName(), o.GetNamespace()
+	default:
+		return
+	}
+
+	var sources []exportSource
+	for _, m := range mc.members {
+		svc := m.exported(name, namespace)
+		if svc == nil {
+			continue
+		}
+		s := exportSource{cluster: m.clusterID, created: m.export(name, namespace).GetCreationTimestamp().Time, typ: mcs.ClusterSetIP}
+		if svc.Headless() {
+			s.typ = mcs.Headless
+		}
+		for _, p := range svc.Ports {
+			if p.Port == -1 {
+				continue
+			}
+			s.ports = append(s.ports, mcs.ServicePort{Name: p.Name, Protocol: p.Protocol, Port: p.Port})
+		}
+		sources = append(sources, s)
+	}
+	idx := object.ServiceKey(name, namespace)
+	mc.conflicts.update(idx, resolveConflict(idx, sources, true))
+}
+
+// conflictEvent emits an event for the conflict on the ServiceExport of every losing member.
+func (mc *memberController) conflictEvent(c *serviceConflict) {
+	name, namespace, ok := strings.Cut(c.Service, ".")
+	if !ok {
+		return
+	}
+	for _, m := range mc.members {
+		if _, ok := c.Losers[m.clusterID]; !ok {
+			continue
+		}
+		emitConflictEvent(m.k8sClient, api.ObjectReference{
+			Kind:       "ServiceExport",
+			APIVersion: mcs.GroupVersion.String(),
+			Name:       name,
+			Namespace:  namespace,
+		}, c)
+	}
+}
+
+// EpIndex returns the Endpoints matching idx from the members that export the service.
+func (mc *memberController) EpIndex(idx string) (eps []*object.Endpoints) {
+	name, namespace, ok := strings.Cut(idx, ".")
+	if !ok {
+		return nil
+	}
+	for _, m := range mc.members {
+		if m.epLister == nil || mc.exported(m, name, namespace) == nil {
+			continue
+		}
+		os, err := m.epLister.ByIndex(epNameNamespaceIndex, idx)
+		if err != nil {
+			continue
+		}
+		for _, o := range os {
+			e, ok := o.(*object.Endpoints)
+			if !ok {
+				continue
+			}
+			eps = append(eps, e)
+		}
+	}
+	return eps
+}
+
+// EndpointsList returns the Endpoints of all exported services of all members.
+func (mc *memberController) EndpointsList() (eps []*object.Endpoints) {
+	for _, m := range mc.members {
+		if m.epLister == nil {
+			continue
+		}
+		for _, o := range m.epLister.List() {
+			e, ok := o.(*object.Endpoints)
+			if !ok {
+				continue
+			}
+			name, _, _ := strings.Cut(e.Index, ".")
+			if mc.exported(m, name, e.GetNamespace()) == nil {
+				continue
+			}
+			eps = append(eps, e)
+		}
+	}
+	return eps
+}
+
+// NamespaceList returns the names of the namespaces of all members.
+func (mc *memberController) NamespaceList() []string {
+	stores := make([]cache.Store, len(mc.members))
+	for i, m := range mc.members {
+		stores[i] = m.nsLister
+	}
+	return namespaceNames(stores...)
+}
+
+// Conflicts returns the conflicts currently detected between the exports of services.
+func (mc *memberController) Conflicts() []*serviceConflict { return mc.conflicts.list() }
+
+// GetNamespaceByName returns the namespace by name from the first member that has it.
+// If nothing is found an error is returned.
+func (mc *memberController) GetNamespaceByName(name string) (*k8sObject.Namespace, error) {
+	for _, m := range mc.members {
+		o, exists, err := m.nsLister.GetByKey(name)
+		if err != nil || !exists {
+			continue
+		}
+		if ns, ok := o.(*k8sObject.Namespace); ok {
+			return ns, nil
+		}
+	}
+	return nil, fmt.Errorf("namespace not found")
+}
+
+// detectChanges detects changes in objects, and updates the modified timestamp
+func (mc *memberController) detectChanges(oldObj, newObj interface{}) {
+	if newObj != nil && oldObj != nil && (oldObj.(meta.Object).GetResourceVersion() == newObj.(meta.Object).GetResourceVersion()) {
+		return
+	}
+	if a, ok := oldObj.(*object.Endpoints); ok {
+		if b, ok := newObj.(*object.Endpoints); ok && endpointsEquivalent(a, b) {
+			return
+		}
+	}
+	mc.updateModified()
+}
+
+func (mc *memberController) Modified() int64 {
+	return atomic.LoadInt64(&mc.modified)
+}
+
+func (mc *memberController) updateModified() {
+	atomic.StoreInt64(&mc.modified, time.Now().Unix())
+}
@@ -0,0 +1,172 @@
+package multicluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+	mcsFake "sigs.k8s.io/mcs-api/pkg/client/clientset/versioned/fake"
+)
+
+func newTestMember(id string, exports []runtime.Object, objs ...runtime.Object) *memberCluster {
+	return &memberCluster{
+		clusterID: id,
+		k8sClient: fake.NewSimpleClientset(objs...),
+		mcsClient: mcsFake.NewSimpleClientset(exports...).MulticlusterV1alpha1(),
+	}
+}
+
+func testService(name, namespace, clusterIP string, port int32) *api.Service {
+	return &api.Service{
+		ObjectMeta: meta.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: api.ServiceSpec{
+			ClusterIP:  clusterIP,
+			ClusterIPs: []string{clusterIP},
+			Ports:      []api.ServicePort{{Name: "http", Protocol: api.ProtocolTCP, Port: port}},
+		},
+	}
+}
+
+func testEndpointSlice(name, namespace, service string, ips ...string) *discovery.EndpointSlice {
+	port := int32(80)
+	pname := "http"
+	proto := api.ProtocolTCP
+	es := &discovery.EndpointSlice{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{discovery.LabelServiceName: service},
+		},
+		AddressType: discovery.AddressTypeIPv4,
+		Ports:       []discovery.EndpointPort{{Name: &pname, Protocol: &proto, Port: &port}},
+	}
+	for _, ip := range ips {
+		es.Endpoints = append(es.Endpoints, discovery.Endpoint{Addresses: []string{ip}})
+	}
+	return es
+}
+
+func testExport(name, namespace string) *mcs.ServiceExport {
+	return &mcs.ServiceExport{ObjectMeta: meta.ObjectMeta{Name: name, Namespace: namespace}}
+}
+
+func runMemberController(t *testing.T, mc *memberController) {
+	t.Helper()
+	go mc.Run()
+	t.Cleanup(func() { mc.Stop() })
+	deadline := time.Now().Add(5 * time.Second)
+	for !mc.HasSynced() {
+		if time.Now().After(deadline) {
+			t.Fatal("member controller did not sync")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMemberController(t *testing.T) {
+	ns := &api.Namespace{ObjectMeta: meta.ObjectMeta{Name: "testns"}}
+	c1 := newTestMember("c1",
+		[]runtime.Object{testExport("hdls1", "testns"), testExport("svc1", "testns")},
+		ns,
+		testService("hdls1", "testns", api.ClusterIPNone, 80),
+		testService("svc1", "testns", "10.96.0.1", 80),
+		testEndpointSlice("hdls1-abc", "testns", "hdls1", "172.0.0.1"),
+	)
+	// c2 has the service, but does not export it.
+	c2 := newTestMember("c2",
+		[]runtime.Object{testExport("hdls1", "testns")},
+		ns,
+		testService("hdls1", "testns", api.ClusterIPNone, 80),
+		testService("svc1", "testns", "10.96.0.2", 80),
+		testEndpointSlice("hdls1-def", "testns", "hdls1", "172.0.1.1"),
+		testEndpointSlice("svc1-def", "testns", "svc1", "172.0.1.2"),
+	)
+
+	mc := newMemberController(context.TODO(), []*memberCluster{c1, c2},
+		map[string][]string{"svc1.testns": {"10.0.0.1"}}, controllerOpts{initEndpointsCache: true})
+	runMemberController(t, mc)
+
+	if n := len(mc.ServiceList()); n != 2 {
+		t.Errorf("Expected 2 ServiceImports, got %d", n)
+	}
+
+	svcs := mc.SvcIndex("svc1.testns")
+	if len(svcs) != 1 {
+		t.Fatalf("Expected 1 ServiceImport for svc1, got %d", len(svcs))
+	}
+	if svcs[0].Type != mcs.ClusterSetIP || len(svcs[0].ClusterIPs) != 1 || svcs[0].ClusterIPs[0] != "10.0.0.1" {
+		t.Errorf("Expected ClusterSetIP service with configured IP, got %v %v", svcs[0].Type, svcs[0].ClusterIPs)
+	}
+	if len(svcs[0].Ports) != 1 || svcs[0].Ports[0].Port != 80 {
+		t.Errorf("Expected port 80, got %v", svcs[0].Ports)
+	}
+
+	svcs = mc.SvcIndex("hdls1.testns")
+	if len(svcs) != 1 || svcs[0].Type != mcs.Headless {
+		t.Fatalf("Expected 1 headless ServiceImport for hdls1, got %v", svcs)
+	}
+
+	eps := mc.EpIndex("hdls1.testns")
+	if len(eps) != 2 {
+		t.Fatalf("Expected endpoints from 2 clusters for hdls1, got %d", len(eps))
+	}
+	clusters := map[string]bool{}
+	for _, ep := range eps {
+		clusters[ep.ClusterId] = true
+	}
+	if !clusters["c1"] || !clusters["c2"] {
+		t.Errorf("Expected endpoints from c1 and c2, got %v", clusters)
+	}
+
+	// svc1 isn't exported from c2, so its slices there must not be served.
+	if eps := mc.EpIndex("svc1.testns"); len(eps) != 0 {
+		t.Errorf("Expected no endpoints for svc1, got %d", len(eps))
+	}
+
+	if _, err := mc.GetNamespaceByName("testns"); err != nil {
+		t.Errorf("Expected namespace testns, got %v", err)
+	}
+	if svcs := mc.SvcIndex("svc0.testns"); len(svcs) != 0 {
+		t.Errorf("Expected no ServiceImport for svc0, got %v", svcs)
+	}
+}
+
+func TestMemberControllerConflict(t *testing.T) {
+	ns := &api.Namespace{ObjectMeta: meta.ObjectMeta{Name: "testns"}}
+	older := testExport("hdls1", "testns")
+	older.CreationTimestamp = meta.NewTime(time.Unix(1000, 0))
+	newer := testExport("hdls1", "testns")
+	newer.CreationTimestamp = meta.NewTime(time.Unix(2000, 0))
+
+	// c2 exported first with a headless service, c1 exports a ClusterSetIP service.
+	c1 := newTestMember("c1", []runtime.Object{newer}, ns,
+		testService("hdls1", "testns", "10.96.0.1", 80),
+		testEndpointSlice("hdls1-abc", "testns", "hdls1", "172.0.0.1"),
+	)
+	c2 := newTestMember("c2", []runtime.Object{older}, ns,
+		testService("hdls1", "testns", api.ClusterIPNone, 80),
+		testEndpointSlice("hdls1-def", "testns", "hdls1", "172.0.1.1"),
+	)
+
+	mc := newMemberController(context.TODO(), []*memberCluster{c1, c2}, nil, controllerOpts{initEndpointsCache: true})
+	runMemberController(t, mc)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !mc.conflicts.lost("hdls1.testns", "c1") {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected c1 to lose the conflict")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	eps := mc.EpIndex("hdls1.testns")
+	if len(eps) != 1 || eps[0].ClusterId != "c2" {
+		t.Errorf("Expected only endpoints of c2, got %v", eps)
+	}
+}
@@ -0,0 +1,61 @@
+package multicluster
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// conflictCount counts conflicts detected between the exports of a service, by reason.
+	conflictCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: plugin.Namespace,
+			Subsystem: pluginName,
+			Name:      "conflicts_total",
+			Help:      "Counter of conflicts detected between clusters exporting the same service.",
+		},
+		[]string{"reason"},
+	)
+	// rejectedCount counts the EndpointSlices rejected as untrusted, by reason. A slice is counted
+	// each time it becomes rejected, not on every event.
+	rejectedCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: plugin.Namespace,
+			Subsystem: pluginName,
+			Name:      "rejected_endpointslices_total",
+			Help:      "Counter of EndpointSlices rejected because of their managing controller or source cluster.",
+		},
+		[]string{"reason"},
+	)
+	// clusterInfo identifies the cluster CoreDNS runs in, and the clusterset it serves.
+	clusterInfo = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: plugin.Namespace,
+			Subsystem: pluginName,
+			Name:      "cluster_info",
+			Help:      "Cluster and clusterset id from the ClusterProperties of the cluster CoreDNS runs in.",
+		},
+		[]string{"cluster_id", "clusterset_id"},
+	)
+	// probeCount counts the health probes of endpoints, by result.
+	probeCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: plugin.Namespace,
+			Subsystem: pluginName,
+			Name:      "health_probes_total",
+			Help:      "Counter of health probes of headless endpoints, by result.",
+		},
+		[]string{"result"},
+	)
+	// unhealthyEndpoints is the number of endpoints that failed their last health probe.
+	unhealthyEndpoints = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: plugin.Namespace,
+			Subsystem: pluginName,
+			Name:      "unhealthy_endpoints",
+			Help:      "Number of headless endpoint ports that failed their last health probe.",
+		},
+	)
+)
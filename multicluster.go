@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/coredns/coredns/coremain"
@@ -13,8 +15,10 @@ import (
 	k8sObject "github.com/coredns/coredns/plugin/kubernetes/object"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/plugin/pkg/fall"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
 	"github.com/coredns/coredns/request"
 	"github.com/coredns/multicluster/object"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -53,14 +57,77 @@ type MultiCluster struct {
 	Zones        []string
 	ClientConfig clientcmd.ClientConfig
 	Fall         fall.F
+	Upstream     lookuper
 	controller   controller
 	ttl          uint32
 	opts         controllerOpts
+
+	// mcsVersion is the version of the ServiceImports to watch, or versionAuto to detect it.
+	mcsVersion string
+
+	// file, if set, is a manifest file used instead of the Kubernetes API.
+	file       string
+	fileReload time.Duration
+
+	// members, if set, are the member clusters watched directly instead of a single cluster
+	// holding ServiceImports.
+	members       []memberConfig
+	clusterSetIPs map[string][]string
+
+	drain *drainSet
+	// identity is the cluster CoreDNS runs in, and the clusterset it serves. preferLocal keeps
+	// headless answers in the local cluster if it has endpoints.
+	identity    *clusterIdentity
+	preferLocal bool
+	// aliases are the names clusters are served under instead of their ids.
+	aliases *clusterAliases
+	// health holds the health of the clusters reported by their ClusterProfiles.
+	health *clusterHealth
+	// weights are the relative weights of clusters for headless answers.
+	weights map[string]int
+
+	// subnets map client subnets to preferred clusters for headless answers, longest prefix first.
+	// subnetOnly leaves out the other clusters even if the preferred ones have no endpoints.
+	subnets    []subnetRule
+	subnetOnly bool
+	// zones map client subnets to topology zones, longest prefix first.
+	zones []zoneRule
+
+	affinity *affinityTable
+
+	// prober, if set, probes headless endpoints so unhealthy ones are left out of answers.
+	prober *prober
+
+	// maxAnswers caps the number of endpoints in headless answers, if set. maxAnswersBalanced
+	// spreads the endpoints evenly over the clusters.
+	maxAnswers         int
+	maxAnswersBalanced bool
+
+	// dnsSD enables DNS-SD browsing of the services in a namespace.
+	dnsSD bool
+	// info enables the TXT records describing a service under _info.service.namespace.svc.zone.
+	info bool
+	// statusACL, if set, enables the CHAOS status query for the clients in these networks.
+	statusACL []*net.IPNet
+	// debug, if set, serves the data of the plugin over HTTP.
+	debug *debugServer
+
+	order answerOrder
+	// rrCounter is shared by all copies of m, for the round robin order.
+	rrCounter *atomic.Uint32
 }
 
 func New(zones []string) *MultiCluster {
 	m := MultiCluster{
-		Zones: zones,
+		Zones:      zones,
+		drain:      newDrainSet(),
+		health:     newClusterHealth(),
+		aliases:    newClusterAliases(),
+		identity:   &clusterIdentity{},
+		mcsVersion: versionAuto,
+		Upstream:   upstream.New(),
+		affinity:   newAffinityTable(),
+		rrCounter:  new(atomic.Uint32),
 	}
 
 	m.ttl = defaultTTL
@@ -69,6 +136,13 @@ func New(zones []string) *MultiCluster {
 }
 
 func (m *MultiCluster) InitController(ctx context.Context) (onStart func() error, onShut func() error, err error) {
+	if m.file != "" {
+		return m.initFileController()
+	}
+	if len(m.members) > 0 {
+		return m.initMemberController(ctx)
+	}
+
 	config, err := m.getClientConfig()
 	if err != nil {
 		return nil, nil, err
@@ -79,11 +153,53 @@ func (m *MultiCluster) InitController(ctx context.Context) (onStart func() error
 		return nil, nil, fmt.Errorf("failed to create kubernetes notification controller: %q", err)
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create dynamic client: %q", err)
+	}
+
 	mcsClient, err := mcsClientset.NewForConfig(config)
 
-	m.controller = newController(ctx, kubeClient, mcsClient, m.opts)
+	m.opts.serviceImportVersion = m.mcsVersion
+	if m.mcsVersion == versionAuto {
+		m.opts.serviceImportVersion = detectServiceImportVersion(kubeClient.Discovery())
+	}
 
-	onStart = func() error {
+	m.opts.drain = m.drain
+	m.opts.health = m.health
+	m.opts.identity = m.identity
+	m.controller = newController(ctx, kubeClient, mcsClient, dynamicClient, m.opts)
+
+	return m.controllerHooks(), func() error { return m.controller.Stop() }, err
+}
+
+// initMemberController sets up a controller watching all configured member clusters.
+func (m *MultiCluster) initMemberController(ctx context.Context) (onStart func() error, onShut func() error, err error) {
+	members := make([]*memberCluster, len(m.members))
+	for i, mc := range m.members {
+		config, err := mc.clientConfig.ClientConfig()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load kubeconfig of member %s: %q", mc.clusterID, err)
+		}
+		kubeClient, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create kubernetes notification controller for member %s: %q", mc.clusterID, err)
+		}
+		mcsClient, err := mcsClientset.NewForConfig(config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create mcs client for member %s: %q", mc.clusterID, err)
+		}
+		members[i] = &memberCluster{clusterID: mc.clusterID, k8sClient: kubeClient, mcsClient: mcsClient}
+	}
+
+	m.controller = newMemberController(ctx, members, m.clusterSetIPs, m.opts)
+
+	return m.controllerHooks(), func() error { return m.controller.Stop() }, nil
+}
+
+// controllerHooks returns the startup function that runs the controller and waits for it to sync.
+func (m *MultiCluster) controllerHooks() (onStart func() error) {
+	return func() error {
 		go func() {
 			m.controller.Run()
 		}()
@@ -109,26 +225,52 @@ func (m *MultiCluster) InitController(ctx context.Context) (onStart func() error
 			}
 		}
 	}
+}
 
-	onShut = func() error {
-		return m.controller.Stop()
+// initFileController sets up a controller serving the manifests in m.file.
+func (m *MultiCluster) initFileController() (onStart func() error, onShut func() error, err error) {
+	fc, err := newFileController(m.file, m.fileReload, m.opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load %s: %q", m.file, err)
 	}
+	m.controller = fc
 
-	return onStart, onShut, err
+	onStart = func() error {
+		go fc.Run()
+		return nil
+	}
+	onShut = func() error {
+		return fc.Stop()
+	}
+	return onStart, onShut, nil
 }
 
 func (m MultiCluster) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
 	state := request.Request{W: w, Req: r}
 
+	if m.statusACL != nil && isStatusQuery(state) {
+		return m.serveStatus(w, state)
+	}
+
 	qname := state.QName()
 
 	zone := plugin.Zones(m.Zones).Matches(qname)
 	if zone == "" {
 		return plugin.NextOrFailure(m.Name(), m.Next, ctx, w, r)
 	}
+	if ecs := clientSubnet(r); ecs != nil {
+		w = newSubnetWriter(w, ecs)
+		state.W = w
+	}
 	zone = qname[len(qname)-len(zone):] // maintain case of original query
 	state.Zone = zone
 
+	if m.dnsSD {
+		if b, ok := parseBrowseRequest(state.Name(), zone); ok {
+			return m.serveBrowse(ctx, w, state, b, zone)
+		}
+	}
+
 	var (
 		records   []dns.RR
 		extra     []dns.RR
@@ -145,6 +287,8 @@ func (m MultiCluster) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns
 		records, truncated, err = plugin.TXT(ctx, &m, zone, state, nil, plugin.Options{})
 	case dns.TypeSRV:
 		records, extra, err = plugin.SRV(ctx, &m, zone, state, plugin.Options{})
+	case dns.TypeHTTPS, dns.TypeSVCB:
+		records, err = m.serviceBindings(ctx, state)
 	case dns.TypeSOA:
 		if qname == zone {
 			records, err = plugin.SOA(ctx, &m, zone, state, plugin.Options{})
@@ -164,7 +308,7 @@ func (m MultiCluster) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns
 
 	if m.IsNameError(err) {
 		if m.Fall.Through(state.Name()) {
-			return plugin.NextOrFailure(m.Name(), m.Next, ctx, w, r)
+			return plugin.NextOrFailure(m.Name(), m.Next, ctx, nextWriter(w), r)
 		}
 		if !m.controller.HasSynced() {
 			// If we haven't synchronized with the kubernetes cluster, return server failure
@@ -203,12 +347,32 @@ func (m MultiCluster) Services(ctx context.Context, state request.Request, exact
 		// 1 label + zone, label must be "dns-version".
 		t, _ := dnsutil.TrimZone(state.Name(), state.Zone)
 
-		// Hard code the only valid TXT - "dns-version.<zone>"
+		// Hard code the valid TXT - "dns-version.<zone>", and the identity once known
 		segs := dns.SplitDomainName(t)
 		if len(segs) == 1 && segs[0] == "dns-version" {
 			svc := msg.Service{Text: DNSSchemaVersion, TTL: 28800, Key: msg.Path(state.QName(), coredns)}
 			return []msg.Service{svc}, nil
 		}
+		if len(segs) == 1 {
+			clusterID, clusterSetID := m.identity.get()
+			text := ""
+			switch segs[0] {
+			case "cluster-id":
+				text = clusterID
+			case "clusterset-id":
+				text = clusterSetID
+			}
+			if text != "" {
+				svc := msg.Service{Text: text, TTL: m.ttl, Key: msg.Path(state.QName(), coredns)}
+				return []msg.Service{svc}, nil
+			}
+		}
+
+		if m.info {
+			if name, namespace, ok := parseInfoName(state.Name(), state.Zone); ok {
+				return m.serviceInfo(state, name, namespace)
+			}
+		}
 
 		// Check if we have an existing record for this query of another type
 		services, _ := m.Records(ctx, state, false)
@@ -233,14 +397,23 @@ func (m MultiCluster) Reverse(ctx context.Context, state request.Request, exact
 	return nil, errors.New("reverse lookup is not supported")
 }
 
-// Lookup is used to find records else where.
+// Lookup is used to find records else where. Names in the zones of the plugin are resolved from
+// the cache, others through the upstream.
 func (m MultiCluster) Lookup(ctx context.Context, state request.Request, name string, typ uint16) (*dns.Msg, error) {
-	return nil, errors.New("external lookup is not supported")
+	if zone := plugin.Zones(m.Zones).Matches(name); zone != "" {
+		return m.lookupLocal(ctx, state, zone, name, typ)
+	}
+	return m.Upstream.Lookup(ctx, state, name, typ)
 }
 
 // Returns _all_ services that matches a certain name.
 // Note: it does not implement a specific service.
 func (m MultiCluster) Records(ctx context.Context, state request.Request, exact bool) ([]msg.Service, error) {
+	if m.info {
+		if name, namespace, ok := parseInfoName(state.Name(), state.Zone); ok {
+			return m.serviceInfo(state, name, namespace)
+		}
+	}
 	r, e := parseRequest(state.Name(), state.Zone)
 	if e != nil {
 		return nil, e
@@ -257,7 +430,7 @@ func (m MultiCluster) Records(ctx context.Context, state request.Request, exact
 		return nil, errNsNotExposed
 	}
 
-	services, err := m.findServices(r, state.Zone)
+	services, err := m.findServices(r, state)
 	return services, err
 }
 
@@ -311,7 +484,15 @@ func (m *MultiCluster) namespaceExists(namespace string) bool {
 	return true
 }
 
-func (m *MultiCluster) findServices(r recordRequest, zone string) (services []msg.Service, err error) {
+// candidate is an endpoint considered for a headless or endpoint answer.
+type candidate struct {
+	cluster  string
+	addr     k8sObject.EndpointAddress
+	topology object.EndpointTopology
+	service  msg.Service
+}
+
+func (m *MultiCluster) findServices(r recordRequest, state request.Request) (services []msg.Service, err error) {
 	if !m.namespaceExists(r.namespace) {
 		return nil, errNoItems
 	}
@@ -338,27 +519,60 @@ func (m *MultiCluster) findServices(r recordRequest, zone string) (services []ms
 	serviceList = m.controller.SvcIndex(idx)
 	endpointsListFunc = func() []*object.Endpoints { return m.controller.EpIndex(idx) }
 
-	zonePath := msg.Path(zone, coredns)
+	zonePath := msg.Path(state.Zone, coredns)
 	for _, svc := range serviceList {
 		if !(match(r.namespace, svc.Namespace) && match(r.service, svc.Name)) {
 			continue
 		}
 
+		// Service aliasing an external name
+		if svc.ExternalName != "" && r.endpoint == "" {
+			for _, s := range m.externalServices(svc, r, zonePath) {
+				err = nil
+				services = append(services, s)
+			}
+			continue
+		}
+
 		// Headless service or endpoint query
 		if svc.Type == mcs.Headless || r.endpoint != "" {
 			if endpointsList == nil {
 				endpointsList = endpointsListFunc()
 			}
 
+			owners := hostnameOwners(endpointsList, object.EndpointsKey(svc.Name, svc.Namespace))
+			var candidates []candidate
 			for _, ep := range endpointsList {
 				if object.EndpointsKey(svc.Name, svc.Namespace) != ep.Index {
 					continue
 				}
+				// ignore stale endpoints of clusters no longer exporting the service
+				if !svc.ExportedBy(ep.ClusterId) {
+					continue
+				}
+				if m.drain.skip(ep.ClusterId, r.endpoint != "") {
+					continue
+				}
+				if m.health.unhealthy(ep.ClusterId) {
+					continue
+				}
+				// domain names can't be queried as endpoints
+				if ep.FQDN && r.endpoint != "" {
+					continue
+				}
 
 				for _, eps := range ep.Subsets {
 					for _, addr := range eps.Addresses {
+						hostname := endpointHostname(addr)
+						if addr.Hostname != "" && owners[hostnameKey{ep.ClusterId, addr.Hostname}] != ep.Name {
+							// the hostname is taken by an endpoint in another EndpointSlice of the cluster
+							if m.opts.labelPolicy == object.LabelReject {
+								continue
+							}
+							hostname = endpointHostname(k8sObject.EndpointAddress{IP: addr.IP})
+						}
 						if r.endpoint != "" {
-							if !match(r.cluster, ep.ClusterId) || !match(r.endpoint, endpointHostname(addr)) {
+							if !m.aliases.match(r.cluster, ep.ClusterId) || !match(r.endpoint, hostname) {
 								continue
 							}
 						}
@@ -368,15 +582,18 @@ func (m *MultiCluster) findServices(r recordRequest, zone string) (services []ms
 								continue
 							}
 							s := msg.Service{Host: addr.IP, Port: int(p.Port), TTL: m.ttl}
-							s.Key = strings.Join([]string{zonePath, Svc, svc.Namespace, svc.Name, ep.ClusterId, endpointHostname(addr)}, "/")
-
-							err = nil
+							s.Key = strings.Join([]string{zonePath, Svc, svc.Namespace, svc.Name, m.aliases.label(ep.ClusterId), hostname}, "/")
 
-							services = append(services, s)
+							candidates = append(candidates, candidate{cluster: ep.ClusterId, addr: addr, topology: ep.Topology[addr.IP], service: s})
 						}
 					}
 				}
 			}
+
+			for _, c := range m.selectCandidates(svc, r, state, candidates) {
+				err = nil
+				services = append(services, c.service)
+			}
 			continue
 		}
 
@@ -395,7 +612,10 @@ func (m *MultiCluster) findServices(r recordRequest, zone string) (services []ms
 			}
 		}
 	}
-	return services, err
+	if m.order == orderHash && len(services) > 1 {
+		narrowScope(state, -1)
+	}
+	return singleTarget(m.orderServices(services, clientIP(state), idx), state.QType()), err
 }
 
 func endpointHostname(addr k8sObject.EndpointAddress) string {
@@ -411,6 +631,37 @@ func endpointHostname(addr k8sObject.EndpointAddress) string {
 	return ""
 }
 
+// hostnameKey is a hostname of an endpoint in a cluster.
+type hostnameKey struct {
+	cluster  string
+	hostname string
+}
+
+// hostnameOwners returns the name of the EndpointSlice owning each hostname of the endpoints with
+// index in eps, by cluster. Collisions within an EndpointSlice are resolved when converting it; across
+// the EndpointSlices of a cluster the one with the lowest name owns the hostname, so the owner doesn't
+// depend on the order of eps.
+func hostnameOwners(eps []*object.Endpoints, index string) map[hostnameKey]string {
+	owners := map[hostnameKey]string{}
+	for _, ep := range eps {
+		if ep.Index != index {
+			continue
+		}
+		for _, sub := range ep.Subsets {
+			for _, addr := range sub.Addresses {
+				if addr.Hostname == "" {
+					continue
+				}
+				key := hostnameKey{ep.ClusterId, addr.Hostname}
+				if owner, ok := owners[key]; !ok || ep.Name < owner {
+					owners[key] = ep.Name
+				}
+			}
+		}
+	}
+	return owners
+}
+
 // match checks if a and b are equal.
 func match(a, b string) bool {
 	return strings.EqualFold(a, b)
@@ -158,6 +158,21 @@ var dnsTestCases = []test.Case{
 			test.A("hdls1.testns.svc.cluster.local.	5	IN	A	172.0.0.5"),
 		},
 	},
+	// A Service (Headless) ignoring endpoints of clusters that no longer export it
+	{
+		Qname: "hdlsstale.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("hdlsstale.testns.svc.cluster.local.	5	IN	A	172.0.0.30"),
+		},
+	},
+	{
+		Qname: "172-0-0-31.unexported.hdlsstale.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
 	// AAAA
 	{
 		Qname: "5678-abcd--2.clusterid.hdls1.testns.svc.cluster.local", Qtype: dns.TypeAAAA,
@@ -537,6 +552,14 @@ var svcIndex = map[string][]*object.ServiceImport{
 			Type:      mcs.Headless,
 		},
 	},
+	"hdlsstale.testns": {
+		{
+			Name:      "hdlsstale",
+			Namespace: "testns",
+			Type:      mcs.Headless,
+			Clusters:  []string{"clusterid"},
+		},
+	},
 	"svc-dual-stack.testns": {
 		{
 			Name:       "svc-dual-stack",
@@ -635,6 +658,36 @@ var epsIndex = map[string][]*object.Endpoints{
 		},
 		ClusterId: "clusterid",
 	}},
+	"hdlsstale.testns": {
+		{
+			Endpoints: k8sObject.Endpoints{
+				Subsets: []k8sObject.EndpointSubset{
+					{
+						Addresses: []k8sObject.EndpointAddress{{IP: "172.0.0.30"}},
+						Ports:     []k8sObject.EndpointPort{{Port: 80, Protocol: "tcp", Name: "http"}},
+					},
+				},
+				Name:      "hdlsstale-slice1",
+				Namespace: "testns",
+				Index:     object.EndpointsKey("hdlsstale", "testns"),
+			},
+			ClusterId: "clusterid",
+		},
+		{
+			Endpoints: k8sObject.Endpoints{
+				Subsets: []k8sObject.EndpointSubset{
+					{
+						Addresses: []k8sObject.EndpointAddress{{IP: "172.0.0.31"}},
+						Ports:     []k8sObject.EndpointPort{{Port: 80, Protocol: "tcp", Name: "http"}},
+					},
+				},
+				Name:      "hdlsstale-slice2",
+				Namespace: "testns",
+				Index:     object.EndpointsKey("hdlsstale", "testns"),
+			},
+			ClusterId: "unexported",
+		},
+	},
 	"hdlsprtls.testns": {{
 		Endpoints: k8sObject.Endpoints{
 			Subsets: []k8sObject.EndpointSubset{
@@ -2,27 +2,65 @@ package object
 
 import (
 	"maps"
+	"slices"
+	"time"
 
 	"github.com/coredns/coredns/plugin/kubernetes/object"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
 	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
 
+	discovery "k8s.io/api/discovery/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+var log = clog.NewWithPlugin("multicluster")
+
 // Endpoints is a stripped down api.Endpoints with only the items we need for CoreDNS.
 type Endpoints struct {
 	object.Endpoints
 	ClusterId string
+	// Created is the creation time of the EndpointSlice.
+	Created time.Time
+	// Topology holds the topology of the endpoints, by address. Endpoints without
+	// topology information are left out.
+	Topology map[string]EndpointTopology
+	// FQDN is set if the addresses are domain names rather than IPs.
+	FQDN bool
 	*object.Empty
 }
 
+// EndpointTopology is the topology of an endpoint. The node name is kept in the EndpointAddress.
+type EndpointTopology struct {
+	// Zone is the zone the endpoint is in.
+	Zone string
+	// ForZones are the zones the endpoint should serve, from the topology aware routing hints.
+	ForZones []string
+}
+
 // EndpointsKey returns a string using for the index.
 func EndpointsKey(name, namespace string) string { return name + "." + namespace }
 
-// EndpointSliceToEndpoints converts a *discovery.EndpointSlice to a *Endpoints.
+// EndpointSliceToEndpoints converts a *discovery.EndpointSlice to a *Endpoints, sanitizing invalid labels.
 func EndpointSliceToEndpoints(obj meta.Object) (meta.Object, error) {
+	return endpointSliceToEndpoints(obj, LabelSanitize)
+}
+
+// EndpointSliceConverter returns a function converting a *discovery.EndpointSlice to a *Endpoints,
+// applying policy to invalid cluster ids and endpoint hostnames.
+func EndpointSliceConverter(policy LabelPolicy) object.ToFunc {
+	return func(obj meta.Object) (meta.Object, error) { return endpointSliceToEndpoints(obj, policy) }
+}
+
+func endpointSliceToEndpoints(obj meta.Object, policy LabelPolicy) (meta.Object, error) {
 	labels := maps.Clone(obj.GetLabels())
+	created := obj.GetCreationTimestamp().Time
+	topology := endpointSliceTopology(obj)
+	hostnames := endpointSliceHostnames(obj, policy)
+	fqdn := false
+	if slice, ok := obj.(*discovery.EndpointSlice); ok {
+		fqdn = slice.AddressType == discovery.AddressTypeFQDN
+	}
 	ends, err := object.EndpointSliceToEndpoints(obj)
 	if err != nil {
 		return nil, err
@@ -30,20 +68,159 @@ func EndpointSliceToEndpoints(obj meta.Object) (meta.Object, error) {
 	e := &Endpoints{
 		Endpoints: *ends.(*object.Endpoints),
 		ClusterId: labels[mcs.LabelSourceCluster],
+		Created:   created,
+		Topology:  topology,
+		FQDN:      fqdn,
 	}
 	e.Endpoints.Index = EndpointsKey(labels[mcs.LabelServiceName], ends.GetNamespace())
 
+	if e.ClusterId != "" && !ValidLabel(e.ClusterId) {
+		if policy == LabelReject {
+			log.Warningf("Ignoring endpoints of EndpointSlice %s/%s: cluster id %q is not a valid DNS label", e.GetNamespace(), e.GetName(), e.ClusterId)
+			for i := range e.Subsets {
+				e.Subsets[i].Addresses = nil
+			}
+			e.IndexIP = nil
+			return e, nil
+		}
+		e.ClusterId = SanitizeLabel(e.ClusterId)
+	}
+
+	if len(hostnames) > 0 {
+		e.IndexIP = nil
+		for i := range e.Subsets {
+			addresses := e.Subsets[i].Addresses[:0]
+			for _, addr := range e.Subsets[i].Addresses {
+				if h, ok := hostnames[addr.IP]; ok {
+					if h.drop {
+						continue
+					}
+					addr.Hostname = h.hostname
+				}
+				addresses = append(addresses, addr)
+				e.IndexIP = append(e.IndexIP, addr.IP)
+			}
+			e.Subsets[i].Addresses = addresses
+		}
+	}
+
 	return e, nil
 }
 
+// endpointHostname is the hostname an endpoint is served under, or drop if it is left out.
+type endpointHostname struct {
+	hostname string
+	drop     bool
+}
+
+// endpointSliceHostnames returns the hostnames of the ready endpoints of obj that must be changed
+// according to policy, by address. Hostnames that aren't valid DNS labels are sanitized or dropped.
+// If several endpoints have the same hostname, the first keeps it. The others are served under their
+// address based name, or dropped.
+func endpointSliceHostnames(obj meta.Object, policy LabelPolicy) map[string]endpointHostname {
+	slice, ok := obj.(*discovery.EndpointSlice)
+	if !ok {
+		return nil
+	}
+	var (
+		hostnames map[string]endpointHostname
+		owners    = map[string]int{}
+	)
+	set := func(addresses []string, h endpointHostname) {
+		if hostnames == nil {
+			hostnames = map[string]endpointHostname{}
+		}
+		for _, a := range addresses {
+			hostnames[a] = h
+		}
+	}
+	for i, end := range slice.Endpoints {
+		if end.Hostname == nil || *end.Hostname == "" {
+			continue
+		}
+		if end.Conditions.Ready != nil && !*end.Conditions.Ready {
+			continue
+		}
+		hostname := *end.Hostname
+		if !ValidLabel(hostname) {
+			if policy == LabelReject {
+				log.Warningf("Ignoring endpoint %v of EndpointSlice %s/%s: hostname %q is not a valid DNS label", end.Addresses, slice.GetNamespace(), slice.GetName(), hostname)
+				set(end.Addresses, endpointHostname{drop: true})
+				continue
+			}
+			hostname = SanitizeLabel(hostname)
+			set(end.Addresses, endpointHostname{hostname: hostname})
+		}
+		if owner, ok := owners[hostname]; ok && owner != i {
+			log.Warningf("Endpoint %v of EndpointSlice %s/%s has the same hostname %q as another endpoint", end.Addresses, slice.GetNamespace(), slice.GetName(), hostname)
+			set(end.Addresses, endpointHostname{drop: policy == LabelReject})
+			continue
+		}
+		owners[hostname] = i
+	}
+	return hostnames
+}
+
+// endpointSliceTopology returns the topology of the endpoints of obj, if it is a *discovery.EndpointSlice.
+func endpointSliceTopology(obj meta.Object) map[string]EndpointTopology {
+	slice, ok := obj.(*discovery.EndpointSlice)
+	if !ok {
+		return nil
+	}
+	var topology map[string]EndpointTopology
+	for _, end := range slice.Endpoints {
+		var t EndpointTopology
+		if end.Zone != nil {
+			t.Zone = *end.Zone
+		}
+		if end.Hints != nil {
+			for _, z := range end.Hints.ForZones {
+				t.ForZones = append(t.ForZones, z.Name)
+			}
+		}
+		if t.Zone == "" && len(t.ForZones) == 0 {
+			continue
+		}
+		if topology == nil {
+			topology = map[string]EndpointTopology{}
+		}
+		for _, a := range end.Addresses {
+			topology[a] = t
+		}
+	}
+	return topology
+}
+
+// InZone returns true if the endpoint should serve clients in zone. Endpoints with topology hints serve
+// the zones of the hints, others the zone they are in.
+func (t EndpointTopology) InZone(zone string) bool {
+	if len(t.ForZones) > 0 {
+		for _, z := range t.ForZones {
+			if z == zone {
+				return true
+			}
+		}
+		return false
+	}
+	return t.Zone == zone
+}
+
 var _ runtime.Object = &Endpoints{}
 
 // DeepCopyObject implements the ObjectKind interface.
 func (e *Endpoints) DeepCopyObject() runtime.Object {
 	e1 := &Endpoints{
 		ClusterId: e.ClusterId,
+		Created:   e.Created,
+		FQDN:      e.FQDN,
 		Endpoints: *e.Endpoints.DeepCopyObject().(*object.Endpoints),
 	}
+	if e.Topology != nil {
+		e1.Topology = make(map[string]EndpointTopology, len(e.Topology))
+		for ip, t := range e.Topology {
+			e1.Topology[ip] = EndpointTopology{Zone: t.Zone, ForZones: slices.Clone(t.ForZones)}
+		}
+	}
 	return e1
 }
 
@@ -0,0 +1,55 @@
+package object
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// LabelPolicy is what to do with cluster ids and endpoint hostnames that aren't valid DNS labels.
+type LabelPolicy int
+
+const (
+	// LabelSanitize rewrites invalid labels to valid ones, see SanitizeLabel. Endpoints whose
+	// hostname collides with the one of another endpoint of the same EndpointSlice are served
+	// under their address based name.
+	LabelSanitize LabelPolicy = iota
+	// LabelReject leaves out the endpoints of EndpointSlices with an invalid cluster id, and
+	// endpoints with an invalid or colliding hostname.
+	LabelReject
+)
+
+// ValidLabel returns true if s is a valid DNS label as defined by RFC 1123.
+func ValidLabel(s string) bool { return len(validation.IsDNS1123Label(s)) == 0 }
+
+// SanitizeLabel deterministically rewrites s to a valid DNS label. Letters are lowercased, other
+// invalid characters replaced by '-', and leading and trailing '-' removed. Labels that are too long
+// are truncated, and suffixed with a hash of s to keep them distinct.
+func SanitizeLabel(s string) string {
+	if ValidLabel(s) {
+		return s
+	}
+	b := []byte(strings.ToLower(s))
+	for i, c := range b {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			b[i] = '-'
+		}
+	}
+	label := strings.Trim(string(b), "-")
+
+	if label == "" || len(label) > validation.DNS1123LabelMaxLength {
+		h := fnv.New32a()
+		h.Write([]byte(s))
+		suffix := fmt.Sprintf("%08x", h.Sum32())
+		if max := validation.DNS1123LabelMaxLength - len(suffix) - 1; len(label) > max {
+			label = strings.TrimRight(label[:max], "-")
+		}
+		if label == "" {
+			return suffix
+		}
+		label += "-" + suffix
+	}
+	return label
+}
@@ -2,13 +2,25 @@ package object
 
 import (
 	"fmt"
+	"maps"
+	"strconv"
+	"strings"
 
 	"github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/miekg/dns"
+	api "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
 )
 
+const (
+	// WeightsAnnotation on a ServiceImport holds comma separated cluster=weight pairs.
+	WeightsAnnotation = "multicluster.coredns.io/weights"
+	// ExternalNameAnnotation on a ServiceImport holds a domain name the service is an alias for.
+	ExternalNameAnnotation = "multicluster.coredns.io/external-name"
+)
+
 // ServiceImport is a stripped down api.ServiceImport with only the items we need for CoreDNS.
 type ServiceImport struct {
 	Version    string
@@ -18,6 +30,15 @@ type ServiceImport struct {
 	ClusterIPs []string
 	Type       mcs.ServiceImportType
 	Ports      []mcs.ServicePort
+	// Clusters lists the clusters currently exporting the service.
+	Clusters []string
+	// Weights are the relative weights of the clusters, from the WeightsAnnotation.
+	Weights map[string]int
+	// SessionAffinity and SessionAffinityTimeout (in seconds) are the ServiceImport's session affinity.
+	SessionAffinity        api.ServiceAffinity
+	SessionAffinityTimeout int32
+	// ExternalName is the domain name the service is an alias for, from the ExternalNameAnnotation.
+	ExternalName string
 
 	*object.Empty
 }
@@ -50,10 +71,64 @@ func ToServiceImport(obj meta.Object) (meta.Object, error) {
 		copy(s.Ports, svc.Spec.Ports)
 	}
 
+	if svc.Spec.SessionAffinity == api.ServiceAffinityClientIP {
+		s.SessionAffinity = api.ServiceAffinityClientIP
+		s.SessionAffinityTimeout = api.DefaultClientIPServiceAffinitySeconds
+		if c := svc.Spec.SessionAffinityConfig; c != nil && c.ClientIP != nil && c.ClientIP.TimeoutSeconds != nil {
+			s.SessionAffinityTimeout = *c.ClientIP.TimeoutSeconds
+		}
+	}
+
+	if name := svc.GetAnnotations()[ExternalNameAnnotation]; name != "" {
+		s.ExternalName = dns.Fqdn(name)
+	}
+
+	if w, ok := svc.GetAnnotations()[WeightsAnnotation]; ok {
+		s.Weights = ParseWeights(w)
+	}
+
+	if len(svc.Status.Clusters) > 0 {
+		s.Clusters = make([]string, len(svc.Status.Clusters))
+		for i, c := range svc.Status.Clusters {
+			s.Clusters[i] = c.Cluster
+		}
+	}
+
 	*svc = mcs.ServiceImport{}
 	return s, nil
 }
 
+// ParseWeights parses comma separated cluster=weight pairs, ignoring malformed pairs and negative weights.
+func ParseWeights(value string) map[string]int {
+	weights := map[string]int{}
+	for _, pair := range strings.Split(value, ",") {
+		cluster, weight, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || cluster == "" {
+			continue
+		}
+		w, err := strconv.Atoi(strings.TrimSpace(weight))
+		if err != nil || w < 0 {
+			continue
+		}
+		weights[strings.TrimSpace(cluster)] = w
+	}
+	return weights
+}
+
+// ExportedBy returns true if cluster exports the service. If the exporting clusters
+// are not known, every cluster is assumed to export it.
+func (s *ServiceImport) ExportedBy(cluster string) bool {
+	if len(s.Clusters) == 0 {
+		return true
+	}
+	for _, c := range s.Clusters {
+		if c == cluster {
+			return true
+		}
+	}
+	return false
+}
+
 var _ runtime.Object = &ServiceImport{}
 
 // DeepCopyObject implements the ObjectKind interface.
@@ -66,9 +141,18 @@ func (s *ServiceImport) DeepCopyObject() runtime.Object {
 		Type:       s.Type,
 		ClusterIPs: make([]string, len(s.ClusterIPs)),
 		Ports:      make([]mcs.ServicePort, len(s.Ports)),
+		Clusters:   make([]string, len(s.Clusters)),
+
+		SessionAffinity:        s.SessionAffinity,
+		SessionAffinityTimeout: s.SessionAffinityTimeout,
+		ExternalName:           s.ExternalName,
 	}
 	copy(s1.ClusterIPs, s.ClusterIPs)
 	copy(s1.Ports, s.Ports)
+	copy(s1.Clusters, s.Clusters)
+	if s.Weights != nil {
+		s1.Weights = maps.Clone(s.Weights)
+	}
 	return s1
 }
 
@@ -0,0 +1,87 @@
+package object
+
+import (
+	"fmt"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// serviceImportSpecFields are the fields of a v1alpha1 ServiceImportSpec.
+var serviceImportSpecFields = []string{"ports", "ips", "type", "sessionAffinity", "sessionAffinityConfig"}
+
+// UnstructuredToServiceImport converts a ServiceImport of a supported version, as an
+// *unstructured.Unstructured, to a *ServiceImport. An unknown version is an error.
+func UnstructuredToServiceImport(obj meta.Object) (meta.Object, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object %v", obj)
+	}
+
+	var (
+		si  *mcs.ServiceImport
+		err error
+	)
+	switch u.GetAPIVersion() {
+	case mcs.GroupVersion.Group + "/v1alpha1":
+		si, err = v1alpha1ToServiceImport(u)
+	case mcs.GroupVersion.Group + "/v1beta1":
+		si, err = v1beta1ToServiceImport(u)
+	default:
+		return nil, fmt.Errorf("unsupported ServiceImport version %q for %s/%s", u.GetAPIVersion(), u.GetNamespace(), u.GetName())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid ServiceImport %s/%s: %v", u.GetNamespace(), u.GetName(), err)
+	}
+
+	return ToServiceImport(si)
+}
+
+// v1alpha1ToServiceImport converts a v1alpha1 ServiceImport, which has the fields in its spec.
+func v1alpha1ToServiceImport(u *unstructured.Unstructured) (*mcs.ServiceImport, error) {
+	si := newServiceImport(u)
+	if spec, ok, _ := unstructured.NestedMap(u.Object, "spec"); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &si.Spec); err != nil {
+			return nil, err
+		}
+	}
+	return si, serviceImportStatus(u, si)
+}
+
+// v1beta1ToServiceImport converts a v1beta1 ServiceImport, which has the fields of the v1alpha1
+// spec at the root of the object.
+func v1beta1ToServiceImport(u *unstructured.Unstructured) (*mcs.ServiceImport, error) {
+	si := newServiceImport(u)
+	spec := map[string]interface{}{}
+	for _, field := range serviceImportSpecFields {
+		if v, ok := u.Object[field]; ok && v != nil {
+			spec[field] = v
+		}
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &si.Spec); err != nil {
+		return nil, err
+	}
+	return si, serviceImportStatus(u, si)
+}
+
+func newServiceImport(u *unstructured.Unstructured) *mcs.ServiceImport {
+	return &mcs.ServiceImport{
+		ObjectMeta: meta.ObjectMeta{
+			Name:            u.GetName(),
+			Namespace:       u.GetNamespace(),
+			ResourceVersion: u.GetResourceVersion(),
+			Annotations:     u.GetAnnotations(),
+		},
+	}
+}
+
+// serviceImportStatus sets the clusters of the status, which is the same in all versions.
+func serviceImportStatus(u *unstructured.Unstructured, si *mcs.ServiceImport) error {
+	clusters, ok, _ := unstructured.NestedFieldNoCopy(u.Object, "status", "clusters")
+	if !ok {
+		return nil
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(map[string]interface{}{"clusters": clusters}, &si.Status)
+}
@@ -0,0 +1,72 @@
+package multicluster
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+)
+
+// answerOrder is the order in which records are returned.
+type answerOrder int
+
+const (
+	// orderAsIs returns the records in the order of the caches.
+	orderAsIs answerOrder = iota
+	// orderRoundRobin rotates the records by one for every query.
+	orderRoundRobin
+	// orderRandom shuffles the records.
+	orderRandom
+	// orderHash rotates the records by a hash of the client address, so every client
+	// consistently gets its own first record.
+	orderHash
+)
+
+func parseAnswerOrder(s string) (answerOrder, error) {
+	switch s {
+	case "as_is":
+		return orderAsIs, nil
+	case "round_robin":
+		return orderRoundRobin, nil
+	case "random":
+		return orderRandom, nil
+	case "hash":
+		return orderHash, nil
+	}
+	return orderAsIs, fmt.Errorf("unknown order '%s'", s)
+}
+
+// orderServices reorders services in place according to m.order. Key identifies the queried
+// service for the hash order.
+func (m *MultiCluster) orderServices(services []msg.Service, client net.IP, key string) []msg.Service {
+	n := len(services)
+	if n < 2 {
+		return services
+	}
+	switch m.order {
+	case orderRoundRobin:
+		rotate(services, int(m.rrCounter.Add(1)%uint32(n)))
+	case orderRandom:
+		rand.Shuffle(n, func(i, j int) { services[i], services[j] = services[j], services[i] })
+	case orderHash:
+		rotate(services, int(clientHash(client, key)%uint32(n)))
+	}
+	return services
+}
+
+// rotate rotates s left by k.
+func rotate(s []msg.Service, k int) {
+	if k == 0 {
+		return
+	}
+	reverse(s[:k])
+	reverse(s[k:])
+	reverse(s)
+}
+
+func reverse(s []msg.Service) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
@@ -0,0 +1,61 @@
+package multicluster
+
+import (
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+)
+
+func orderTestServices() []msg.Service {
+	return []msg.Service{{Host: "10.0.0.1"}, {Host: "10.0.0.2"}, {Host: "10.0.0.3"}}
+}
+
+func hosts(services []msg.Service) (h []string) {
+	for _, s := range services {
+		h = append(h, s.Host)
+	}
+	return h
+}
+
+func TestOrderServices(t *testing.T) {
+	m := New([]string{"cluster.local."})
+
+	if h := hosts(m.orderServices(orderTestServices(), nil, "svc.ns")); h[0] != "10.0.0.1" || h[2] != "10.0.0.3" {
+		t.Errorf("Expected records as is, got %v", h)
+	}
+
+	m.order = orderRoundRobin
+	first := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		h := hosts(m.orderServices(orderTestServices(), nil, "svc.ns"))
+		first[h[0]] = true
+		if len(h) != 3 {
+			t.Fatalf("Expected 3 records, got %v", h)
+		}
+	}
+	if len(first) != 3 {
+		t.Errorf("Expected every record to be first once, got %v", first)
+	}
+
+	m.order = orderHash
+	client := net.ParseIP("10.240.0.1")
+	h1 := hosts(m.orderServices(orderTestServices(), client, "svc.ns"))
+	h2 := hosts(m.orderServices(orderTestServices(), client, "svc.ns"))
+	if h1[0] != h2[0] || h1[1] != h2[1] || h1[2] != h2[2] {
+		t.Errorf("Expected the same order for the same client, got %v and %v", h1, h2)
+	}
+
+	m.order = orderRandom
+	if h := hosts(m.orderServices(orderTestServices(), nil, "svc.ns")); len(h) != 3 {
+		t.Errorf("Expected 3 records, got %v", h)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	s := orderTestServices()
+	rotate(s, 1)
+	if h := hosts(s); h[0] != "10.0.0.2" || h[1] != "10.0.0.3" || h[2] != "10.0.0.1" {
+		t.Errorf("Expected records rotated by one, got %v", h)
+	}
+}
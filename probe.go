@@ -0,0 +1,174 @@
+package multicluster
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const (
+	defaultProbeInterval = 10 * time.Second
+	defaultProbeTimeout  = time.Second
+	// probeConcurrency is the maximum number of probes in flight.
+	probeConcurrency = 32
+)
+
+// prober periodically connects to the TCP ports of headless endpoints and keeps track of the ones
+// that don't accept connections.
+type prober struct {
+	interval time.Duration
+	timeout  time.Duration
+	dial     func(ctx context.Context, network, address string) (net.Conn, error)
+
+	sync.RWMutex
+	// unhealthy holds the host:port of the endpoints that failed their last probe.
+	unhealthy map[string]struct{}
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+func newProber(interval, timeout time.Duration) *prober {
+	return &prober{
+		interval:  interval,
+		timeout:   timeout,
+		dial:      (&net.Dialer{}).DialContext,
+		unhealthy: map[string]struct{}{},
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Run probes the endpoints of ctl every interval, until Stop is called.
+func (p *prober) Run(ctl controller) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.probe(probeTargets(ctl))
+		select {
+		case <-p.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop stops the prober.
+func (p *prober) Stop() error {
+	p.stopOnce.Do(func() { close(p.stopCh) })
+	return nil
+}
+
+// healthy returns false if the endpoint host:port failed its last probe.
+func (p *prober) healthy(host string, port int) bool {
+	p.RLock()
+	defer p.RUnlock()
+	_, ok := p.unhealthy[net.JoinHostPort(host, strconv.Itoa(port))]
+	return !ok
+}
+
+// probeTargets returns the host:port of the TCP ports of the endpoints of headless services of ctl.
+func probeTargets(ctl controller) []string {
+	var targets []string
+	seen := map[string]struct{}{}
+	headless := map[string]bool{}
+	for _, ep := range ctl.EndpointsList() {
+		h, ok := headless[ep.Index]
+		if !ok {
+			for _, svc := range ctl.SvcIndex(ep.Index) {
+				if svc.Type == mcs.Headless {
+					h = true
+				}
+			}
+			headless[ep.Index] = h
+		}
+		if !h || ep.FQDN {
+			continue
+		}
+		for _, eps := range ep.Subsets {
+			for _, p := range eps.Ports {
+				if p.Port <= 0 || (p.Protocol != "" && !strings.EqualFold(p.Protocol, string(api.ProtocolTCP))) {
+					continue
+				}
+				for _, addr := range eps.Addresses {
+					target := net.JoinHostPort(addr.IP, strconv.Itoa(int(p.Port)))
+					if _, ok := seen[target]; ok {
+						continue
+					}
+					seen[target] = struct{}{}
+					targets = append(targets, target)
+				}
+			}
+		}
+	}
+	return targets
+}
+
+// probe connects to every target and replaces the unhealthy endpoints with the ones that failed.
+func (p *prober) probe(targets []string) {
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		unhealthy = map[string]struct{}{}
+		sem       = make(chan struct{}, probeConcurrency)
+	)
+	for _, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(target string) {
+			defer func() { <-sem; wg.Done() }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+			defer cancel()
+			conn, err := p.dial(ctx, "tcp", target)
+			if err != nil {
+				probeCount.WithLabelValues("failure").Inc()
+				mu.Lock()
+				unhealthy[target] = struct{}{}
+				mu.Unlock()
+				return
+			}
+			conn.Close()
+			probeCount.WithLabelValues("success").Inc()
+		}(target)
+	}
+	wg.Wait()
+
+	p.Lock()
+	for target := range unhealthy {
+		if _, ok := p.unhealthy[target]; !ok {
+			log.Warningf("Endpoint %s failed its health probe", target)
+		}
+	}
+	for target := range p.unhealthy {
+		if _, ok := unhealthy[target]; !ok {
+			log.Infof("Endpoint %s passed its health probe", target)
+		}
+	}
+	p.unhealthy = unhealthy
+	p.Unlock()
+
+	unhealthyEndpoints.Set(float64(len(unhealthy)))
+}
+
+// healthyCandidates leaves out the candidates that failed their health probe, unless all of them did.
+func (m *MultiCluster) healthyCandidates(candidates []candidate) []candidate {
+	if m.prober == nil {
+		return candidates
+	}
+	healthy := candidates[:0:0]
+	for _, c := range candidates {
+		if m.prober.healthy(c.service.Host, c.service.Port) {
+			healthy = append(healthy, c)
+		}
+	}
+	if len(healthy) == 0 {
+		return candidates
+	}
+	return healthy
+}
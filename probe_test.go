@@ -0,0 +1,144 @@
+package multicluster
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	api "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+	mcsFake "sigs.k8s.io/mcs-api/pkg/client/clientset/versioned/fake"
+)
+
+// newControllerWithoutEndpoints returns a synced controller that doesn't watch endpoints, as with
+// noendpoints.
+func newControllerWithoutEndpoints(t *testing.T) *control {
+	t.Helper()
+	si := &mcs.ServiceImport{
+		ObjectMeta: meta.ObjectMeta{Name: "hdls1", Namespace: "testns"},
+		Spec: mcs.ServiceImportSpec{
+			Type:  mcs.Headless,
+			Ports: []mcs.ServicePort{{Name: "http", Protocol: "TCP", Port: 80}},
+		},
+	}
+	k8sClient := fake.NewSimpleClientset(&api.Namespace{ObjectMeta: meta.ObjectMeta{Name: "testns"}})
+	ctl := newController(context.TODO(), k8sClient, mcsFake.NewSimpleClientset(si).MulticlusterV1alpha1(), nil, controllerOpts{})
+	go ctl.Run()
+	t.Cleanup(func() { ctl.Stop() })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !ctl.HasSynced() || len(ctl.ServiceList()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the controller to sync")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return ctl
+}
+
+func TestProbeTargets(t *testing.T) {
+	targets := probeTargets(&controllerMock2{})
+	sort.Strings(targets)
+	// only the TCP ports of headless services are probed
+	expected := []string{
+		"172.0.0.2:80", "172.0.0.30:80", "172.0.0.31:80", "172.0.0.3:80", "172.0.0.4:80", "172.0.0.5:80",
+		"[5678:abcd::1]:80", "[5678:abcd::2]:80",
+	}
+	if strings.Join(targets, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected targets %v, got %v", expected, targets)
+	}
+}
+
+func TestProbeTargetsNoEndpoints(t *testing.T) {
+	if targets := probeTargets(newControllerWithoutEndpoints(t)); len(targets) != 0 {
+		t.Errorf("Expected no targets without endpoints, got %v", targets)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	p := newProber(time.Minute, time.Second)
+	p.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address == "10.0.0.2:80" {
+			return nil, errors.New("connection refused")
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+	p.probe([]string{"10.0.0.1:80", "10.0.0.2:80"})
+
+	if !p.healthy("10.0.0.1", 80) || p.healthy("10.0.0.2", 80) {
+		t.Fatalf("Expected only 10.0.0.2:80 to be unhealthy, got %v", p.unhealthy)
+	}
+	// endpoints that weren't probed are healthy
+	if !p.healthy("10.0.0.3", 80) {
+		t.Error("Expected 10.0.0.3:80 to be healthy")
+	}
+
+	m := New([]string{"cluster.local."})
+	m.prober = p
+	candidates := []candidate{
+		{cluster: "a", service: msg.Service{Host: "10.0.0.1", Port: 80}},
+		{cluster: "a", service: msg.Service{Host: "10.0.0.2", Port: 80}},
+	}
+	if selected := m.healthyCandidates(candidates); len(selected) != 1 || selected[0].service.Host != "10.0.0.1" {
+		t.Errorf("Expected only the healthy endpoint, got %v", selected)
+	}
+	// all endpoints down returns all of them
+	if selected := m.healthyCandidates(candidates[1:]); len(selected) != 1 {
+		t.Errorf("Expected the unhealthy endpoint when all are down, got %v", selected)
+	}
+
+	// recovered endpoints are healthy again
+	p.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+	p.probe([]string{"10.0.0.1:80", "10.0.0.2:80"})
+	if !p.healthy("10.0.0.2", 80) {
+		t.Error("Expected 10.0.0.2:80 to be healthy again")
+	}
+}
+
+func TestProbeDial(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// a port nothing listens on
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	p := newProber(time.Minute, time.Second)
+	p.probe([]string{l.Addr().String(), closedAddr})
+
+	if _, ok := p.unhealthy[l.Addr().String()]; ok {
+		t.Errorf("Expected %s to be healthy", l.Addr())
+	}
+	if _, ok := p.unhealthy[closedAddr]; !ok {
+		t.Errorf("Expected %s to be unhealthy", closedAddr)
+	}
+}
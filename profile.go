@@ -0,0 +1,151 @@
+package multicluster
+
+import (
+	"context"
+	"sync"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+var clusterProfileResource = schema.GroupVersionResource{
+	Group:    "multicluster.x-k8s.io",
+	Version:  "v1alpha1",
+	Resource: "clusterprofiles",
+}
+
+const (
+	// clusterIDProperty is the ClusterProfile property holding the id of the cluster.
+	clusterIDProperty = "cluster.clusterset.k8s.io"
+	// conditionControlPlaneHealthy is the ClusterProfile condition holding the health of the cluster.
+	conditionControlPlaneHealthy = "ControlPlaneHealthy"
+)
+
+// clusterHealth holds the health of the clusters, as reported by their ClusterProfiles.
+type clusterHealth struct {
+	sync.RWMutex
+	// profiles holds the cluster id and health of every ClusterProfile, by namespace/name.
+	profiles map[string]profileHealth
+}
+
+type profileHealth struct {
+	cluster string
+	healthy bool
+}
+
+func newClusterHealth() *clusterHealth {
+	return &clusterHealth{profiles: map[string]profileHealth{}}
+}
+
+// unhealthy returns true if a ClusterProfile of cluster reports it as unhealthy.
+func (h *clusterHealth) unhealthy(cluster string) bool {
+	h.RLock()
+	defer h.RUnlock()
+	for _, p := range h.profiles {
+		if p.cluster == cluster && !p.healthy {
+			return true
+		}
+	}
+	return false
+}
+
+// set records the health of the ClusterProfile key. It returns true if the health of the cluster changed.
+func (h *clusterHealth) set(key string, p profileHealth) bool {
+	h.Lock()
+	defer h.Unlock()
+	old, ok := h.profiles[key]
+	h.profiles[key] = p
+	if ok && old == p {
+		return false
+	}
+	if !p.healthy {
+		log.Warningf("Cluster %s is unhealthy according to ClusterProfile %s", p.cluster, key)
+	} else if ok && !old.healthy {
+		log.Infof("Cluster %s is healthy again according to ClusterProfile %s", p.cluster, key)
+	}
+	return true
+}
+
+// remove forgets the ClusterProfile key.
+func (h *clusterHealth) remove(key string) {
+	h.Lock()
+	defer h.Unlock()
+	delete(h.profiles, key)
+}
+
+// toProfileHealth returns the cluster id and health of a ClusterProfile. The cluster id is the
+// cluster.clusterset.k8s.io property, or the name of the ClusterProfile if it is absent. A cluster
+// is unhealthy only if its ControlPlaneHealthy condition is False.
+func toProfileHealth(u *unstructured.Unstructured) profileHealth {
+	p := profileHealth{cluster: u.GetName(), healthy: true}
+
+	properties, _, _ := unstructured.NestedSlice(u.Object, "status", "properties")
+	for _, prop := range properties {
+		m, ok := prop.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, _ := m["name"].(string); name == clusterIDProperty {
+			if value, _ := m["value"].(string); value != "" {
+				p.cluster = value
+			}
+		}
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, cond := range conditions {
+		m, ok := cond.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if typ, _ := m["type"].(string); typ == conditionControlPlaneHealthy {
+			status, _ := m["status"].(string)
+			p.healthy = status != string(meta.ConditionFalse)
+		}
+	}
+	return p
+}
+
+// watchClusterProfiles returns an informer keeping h in sync with the ClusterProfiles in namespace.
+func watchClusterProfiles(ctx context.Context, client dynamic.Interface, namespace string, h *clusterHealth, onChange func()) cache.Controller {
+	resource := client.Resource(clusterProfileResource).Namespace(namespace)
+	update := func(obj interface{}) {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return
+		}
+		key, _ := cache.MetaNamespaceKeyFunc(u)
+		if h.set(key, toProfileHealth(u)) {
+			onChange()
+		}
+	}
+	_, ctl := cache.NewInformerWithOptions(cache.InformerOptions{
+		ListerWatcher: &cache.ListWatch{
+			ListFunc: func(o meta.ListOptions) (runtime.Object, error) {
+				return resource.List(ctx, o)
+			},
+			WatchFunc: func(o meta.ListOptions) (watch.Interface, error) {
+				return resource.Watch(ctx, o)
+			},
+		},
+		ObjectType: &unstructured.Unstructured{},
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc:    update,
+			UpdateFunc: func(oldObj, newObj interface{}) { update(newObj) },
+			DeleteFunc: func(obj interface{}) {
+				key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+				if err != nil {
+					return
+				}
+				h.remove(key)
+				onChange()
+			},
+		},
+	})
+	return ctl
+}
@@ -0,0 +1,119 @@
+package multicluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func testClusterProfile(name, clusterID, healthy string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "multicluster.x-k8s.io/v1alpha1",
+		"kind":       "ClusterProfile",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "fleet"},
+	}}
+	status := map[string]interface{}{}
+	if clusterID != "" {
+		status["properties"] = []interface{}{
+			map[string]interface{}{"name": clusterIDProperty, "value": clusterID},
+		}
+	}
+	if healthy != "" {
+		status["conditions"] = []interface{}{
+			map[string]interface{}{"type": conditionControlPlaneHealthy, "status": healthy},
+		}
+	}
+	u.Object["status"] = status
+	return u
+}
+
+func TestToProfileHealth(t *testing.T) {
+	tests := []struct {
+		profile *unstructured.Unstructured
+		cluster string
+		healthy bool
+	}{
+		{testClusterProfile("p1", "c1", "True"), "c1", true},
+		{testClusterProfile("p1", "c1", "False"), "c1", false},
+		{testClusterProfile("p1", "c1", "Unknown"), "c1", true},
+		{testClusterProfile("p1", "", "False"), "p1", false},
+		{testClusterProfile("p1", "", ""), "p1", true},
+	}
+	for i, tc := range tests {
+		p := toProfileHealth(tc.profile)
+		if p.cluster != tc.cluster || p.healthy != tc.healthy {
+			t.Errorf("Test %d: expected %s healthy %t, got %s healthy %t", i, tc.cluster, tc.healthy, p.cluster, p.healthy)
+		}
+	}
+}
+
+func TestWatchClusterProfiles(t *testing.T) {
+	profile := testClusterProfile("east", "c1", "False")
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{clusterProfileResource: "ClusterProfileList"}, profile)
+	h := newClusterHealth()
+	ctl := watchClusterProfiles(context.TODO(), client, "fleet", h, func() {})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go ctl.Run(stopCh)
+
+	waitFor := func(cond func() bool, msg string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatal(msg)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor(func() bool { return h.unhealthy("c1") }, "Expected c1 to be unhealthy")
+
+	profile = testClusterProfile("east", "c1", "True")
+	if _, err := client.Resource(clusterProfileResource).Namespace("fleet").Update(context.TODO(), profile, meta.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(func() bool { return !h.unhealthy("c1") }, "Expected c1 to be healthy")
+
+	profile = testClusterProfile("east", "c1", "False")
+	if _, err := client.Resource(clusterProfileResource).Namespace("fleet").Update(context.TODO(), profile, meta.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(func() bool { return h.unhealthy("c1") }, "Expected c1 to be unhealthy again")
+
+	if err := client.Resource(clusterProfileResource).Namespace("fleet").Delete(context.TODO(), "east", meta.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(func() bool { return !h.unhealthy("c1") }, "Expected c1 to be forgotten")
+}
+
+func TestClusterHealthServeDNS(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	m.health.set("fleet/east", profileHealth{cluster: "clusterid", healthy: false})
+
+	tc := test.Case{
+		Qname: "hdls1.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	}
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := m.ServeDNS(context.TODO(), w, tc.Msg()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := test.SortAndCheck(w.Msg, tc); err != nil {
+		t.Error(err)
+	}
+}
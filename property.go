@@ -0,0 +1,116 @@
+package multicluster
+
+import (
+	"context"
+	"sync"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+var clusterPropertyResource = schema.GroupVersionResource{
+	Group:    "about.k8s.io",
+	Version:  "v1alpha1",
+	Resource: "clusterproperties",
+}
+
+// clusterSetIDProperty is the ClusterProperty holding the id of the clusterset. The id of the
+// cluster is held by the clusterIDProperty.
+const clusterSetIDProperty = "clusterset.k8s.io"
+
+// clusterIdentity holds the id of the cluster CoreDNS runs in, and of the clusterset it serves.
+type clusterIdentity struct {
+	sync.RWMutex
+	clusterID    string
+	clusterSetID string
+}
+
+// get returns the cluster and clusterset id. They are empty while unknown.
+func (id *clusterIdentity) get() (clusterID, clusterSetID string) {
+	id.RLock()
+	defer id.RUnlock()
+	return id.clusterID, id.clusterSetID
+}
+
+// set sets the ClusterProperty name to value. Properties other than the cluster and clusterset id are ignored.
+func (id *clusterIdentity) set(name, value string) {
+	id.Lock()
+	defer id.Unlock()
+	switch name {
+	case clusterIDProperty:
+		if id.clusterID == value {
+			return
+		}
+		id.clusterID = value
+	case clusterSetIDProperty:
+		if id.clusterSetID == value {
+			return
+		}
+		id.clusterSetID = value
+	default:
+		return
+	}
+	log.Infof("Running in cluster %q of clusterset %q", id.clusterID, id.clusterSetID)
+	clusterInfo.Reset()
+	clusterInfo.WithLabelValues(id.clusterID, id.clusterSetID).Set(1)
+}
+
+// watchClusterProperties returns an informer keeping id in sync with the ClusterProperties.
+func watchClusterProperties(ctx context.Context, client dynamic.Interface, id *clusterIdentity, onChange func()) cache.Controller {
+	resource := client.Resource(clusterPropertyResource)
+	update := func(obj interface{}) {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return
+		}
+		value, _, _ := unstructured.NestedString(u.Object, "spec", "value")
+		id.set(u.GetName(), value)
+		onChange()
+	}
+	_, ctl := cache.NewInformerWithOptions(cache.InformerOptions{
+		ListerWatcher: &cache.ListWatch{
+			ListFunc: func(o meta.ListOptions) (runtime.Object, error) {
+				return resource.List(ctx, o)
+			},
+			WatchFunc: func(o meta.ListOptions) (watch.Interface, error) {
+				return resource.Watch(ctx, o)
+			},
+		},
+		ObjectType: &unstructured.Unstructured{},
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc:    update,
+			UpdateFunc: func(oldObj, newObj interface{}) { update(newObj) },
+			DeleteFunc: func(obj interface{}) {
+				if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = d.Obj
+				}
+				if u, ok := obj.(*unstructured.Unstructured); ok {
+					id.set(u.GetName(), "")
+					onChange()
+				}
+			},
+		},
+	})
+	return ctl
+}
+
+// localCandidates keeps the candidates of the cluster CoreDNS runs in, if it has any.
+func (m *MultiCluster) localCandidates(candidates []candidate) []candidate {
+	if !m.preferLocal {
+		return candidates
+	}
+	local, _ := m.identity.get()
+	if local == "" {
+		return candidates
+	}
+	selected := clusterCandidates(candidates, local)
+	if len(selected) == 0 {
+		return candidates
+	}
+	return selected
+}
@@ -0,0 +1,116 @@
+package multicluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func testClusterProperty(name, value string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "about.k8s.io/v1alpha1",
+		"kind":       "ClusterProperty",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{"value": value},
+	}}
+}
+
+func TestWatchClusterProperties(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{clusterPropertyResource: "ClusterPropertyList"},
+		testClusterProperty(clusterIDProperty, "7f3c2a9e"),
+		testClusterProperty(clusterSetIDProperty, "prod"),
+		testClusterProperty("region.example.com", "eu-west"),
+	)
+	id := &clusterIdentity{}
+	ctl := watchClusterProperties(context.TODO(), client, id, func() {})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go ctl.Run(stopCh)
+
+	waitFor := func(cluster, clusterSet, msg string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			c, cs := id.get()
+			if c == cluster && cs == clusterSet {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s, got %q and %q", msg, c, cs)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor("7f3c2a9e", "prod", "Expected the identity to be discovered")
+
+	if err := client.Resource(clusterPropertyResource).Delete(context.TODO(), clusterSetIDProperty, meta.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("7f3c2a9e", "", "Expected the clusterset id to be removed")
+}
+
+func TestClusterIdentityServeDNS(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	m.identity.set(clusterIDProperty, "clusterid")
+	m.identity.set(clusterSetIDProperty, "prod")
+
+	tests := []test.Case{
+		{
+			Qname: "cluster-id.cluster.local.", Qtype: dns.TypeTXT,
+			Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{
+				test.TXT("cluster-id.cluster.local.	5	IN	TXT	\"clusterid\""),
+			},
+		},
+		{
+			Qname: "clusterset-id.cluster.local.", Qtype: dns.TypeTXT,
+			Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{
+				test.TXT("clusterset-id.cluster.local.	5	IN	TXT	\"prod\""),
+			},
+		},
+	}
+	for i, tc := range tests {
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := m.ServeDNS(context.TODO(), w, tc.Msg()); err != nil {
+			t.Fatalf("Test %d: expected no error, got %v", i, err)
+		}
+		if err := test.SortAndCheck(w.Msg, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+	}
+}
+
+func TestLocalCandidates(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.preferLocal = true
+
+	// unknown local cluster
+	if selected := m.localCandidates(weightCandidates()); len(selected) != 4 {
+		t.Errorf("Expected all candidates, got %v", selected)
+	}
+
+	m.identity.set(clusterIDProperty, "a")
+	selected := m.localCandidates(weightCandidates())
+	if len(selected) != 2 || selected[0].cluster != "a" || selected[1].cluster != "a" {
+		t.Errorf("Expected the candidates of the local cluster, got %v", selected)
+	}
+
+	// no local endpoints
+	m.identity.set(clusterIDProperty, "d")
+	if selected := m.localCandidates(weightCandidates()); len(selected) != 4 {
+		t.Errorf("Expected all candidates, got %v", selected)
+	}
+}
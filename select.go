@@ -0,0 +1,40 @@
+package multicluster
+
+import (
+	"github.com/coredns/coredns/request"
+	"github.com/coredns/multicluster/object"
+	api "k8s.io/api/core/v1"
+)
+
+// selectCandidates narrows down the endpoints of the headless service svc to the ones that make up
+// the answer for the client of state. Queries for a specific endpoint are answered as is.
+func (m *MultiCluster) selectCandidates(svc *object.ServiceImport, r recordRequest, state request.Request, candidates []candidate) []candidate {
+	if r.endpoint != "" || len(candidates) == 0 {
+		return candidates
+	}
+	client := clientIP(state)
+
+	// Narrow the scope of answers to clients with an EDNS0 Client Subnet to the clients getting
+	// the same answer. The weights and the affinity hash the whole client address.
+	if len(m.subnets) > 0 {
+		narrowScope(state, m.subnetScope(client))
+	}
+	if len(m.zones) > 0 || len(m.weights) > 0 || len(svc.Weights) > 0 {
+		narrowScope(state, -1)
+	}
+
+	candidates = m.healthyCandidates(candidates)
+	candidates = m.preferCandidates(client, candidates)
+	candidates = m.zoneCandidates(client, candidates)
+	candidates = m.localCandidates(candidates)
+	candidates = m.weighCandidates(svc, client, candidates)
+
+	if svc.SessionAffinity == api.ServiceAffinityClientIP && client != nil {
+		narrowScope(state, -1)
+		candidates = m.affinity.stick(svc, client, candidates)
+	}
+
+	candidates = m.limitCandidates(candidates)
+
+	return candidates
+}
@@ -2,10 +2,17 @@ package multicluster
 
 import (
 	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/parse"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/multicluster/object"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -30,6 +37,17 @@ func setup(c *caddy.Controller) error {
 	if onShut != nil {
 		c.OnShutdown(onShut)
 	}
+	if p := multiCluster.prober; p != nil {
+		c.OnStartup(func() error {
+			go p.Run(multiCluster.controller)
+			return nil
+		})
+		c.OnShutdown(p.Stop)
+	}
+	if d := multiCluster.debug; d != nil {
+		c.OnStartup(d.Start)
+		c.OnShutdown(d.Stop)
+	}
 
 	// Add the Plugin to CoreDNS, so Servers can use it in their plugin chain.
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
@@ -59,17 +77,300 @@ func ParseStanza(c *caddy.Controller) (*MultiCluster, error) {
 			if len(args) != 1 && len(args) != 2 {
 				return nil, c.ArgErr()
 			}
-			overrides := &clientcmd.ConfigOverrides{}
+			multiCluster.ClientConfig = clientConfig(args)
+		case "member":
+			args := c.RemainingArgs()
+			if len(args) != 2 && len(args) != 3 {
+				return nil, c.ArgErr()
+			}
+			if !object.ValidLabel(args[0]) {
+				return nil, c.Errf("member cluster id '%s' is not a valid DNS label", args[0])
+			}
+			for _, mc := range multiCluster.members {
+				if mc.clusterID == args[0] {
+					return nil, c.Errf("duplicate member '%s'", args[0])
+				}
+			}
+			multiCluster.members = append(multiCluster.members, memberConfig{clusterID: args[0], clientConfig: clientConfig(args[1:])})
+		case "clusterset_ip":
+			args := c.RemainingArgs()
+			if len(args) < 2 {
+				return nil, c.ArgErr()
+			}
+			namespace, name, ok := strings.Cut(args[0], "/")
+			if !ok || namespace == "" || name == "" {
+				return nil, c.Errf("invalid service '%s', expected NAMESPACE/NAME", args[0])
+			}
+			for _, ip := range args[1:] {
+				if net.ParseIP(ip) == nil {
+					return nil, c.Errf("invalid ClusterSetIP '%s'", ip)
+				}
+			}
+			if multiCluster.clusterSetIPs == nil {
+				multiCluster.clusterSetIPs = make(map[string][]string)
+			}
+			idx := object.ServiceKey(name, namespace)
+			multiCluster.clusterSetIPs[idx] = append(multiCluster.clusterSetIPs[idx], args[1:]...)
+		case "file":
+			args := c.RemainingArgs()
+			if len(args) != 1 && len(args) != 2 {
+				return nil, c.ArgErr()
+			}
+			multiCluster.file = args[0]
 			if len(args) == 2 {
-				overrides.CurrentContext = args[1]
+				d, err := time.ParseDuration(args[1])
+				if err != nil {
+					return nil, c.Errf("invalid file reload interval '%s': %v", args[1], err)
+				}
+				if d <= 0 {
+					return nil, c.Errf("file reload interval must be positive: '%s'", args[1])
+				}
+				multiCluster.fileReload = d
 			}
-			config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-				&clientcmd.ClientConfigLoadingRules{ExplicitPath: args[0]},
-				overrides,
-			)
-			multiCluster.ClientConfig = config
 		case "fallthrough":
 			multiCluster.Fall.SetZonesFromArgs(c.RemainingArgs())
+		case "conflict_events":
+			if len(c.RemainingArgs()) != 0 {
+				return nil, c.ArgErr()
+			}
+			multiCluster.opts.conflictEvents = true
+		case "drain":
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return nil, c.ArgErr()
+			}
+			for _, id := range args {
+				multiCluster.drain.static[id] = struct{}{}
+			}
+		case "drain_configmap":
+			args := c.RemainingArgs()
+			if len(args) != 1 {
+				return nil, c.ArgErr()
+			}
+			namespace, name, ok := strings.Cut(args[0], "/")
+			if !ok || namespace == "" || name == "" {
+				return nil, c.Errf("invalid ConfigMap '%s', expected NAMESPACE/NAME", args[0])
+			}
+			multiCluster.opts.drainNamespace = namespace
+			multiCluster.opts.drainName = name
+		case "drain_explicit":
+			if len(c.RemainingArgs()) != 0 {
+				return nil, c.ArgErr()
+			}
+			multiCluster.drain.allowExplicit = true
+		case "weight":
+			args := c.RemainingArgs()
+			if len(args) != 2 {
+				return nil, c.ArgErr()
+			}
+			w, err := strconv.Atoi(args[1])
+			if err != nil || w < 0 {
+				return nil, c.Errf("invalid weight '%s' for cluster '%s'", args[1], args[0])
+			}
+			if multiCluster.weights == nil {
+				multiCluster.weights = map[string]int{}
+			}
+			multiCluster.weights[args[0]] = w
+		case "order":
+			args := c.RemainingArgs()
+			if len(args) != 1 {
+				return nil, c.ArgErr()
+			}
+			order, err := parseAnswerOrder(args[0])
+			if err != nil {
+				return nil, c.Err(err.Error())
+			}
+			multiCluster.order = order
+		case "max_answers":
+			args := c.RemainingArgs()
+			if len(args) < 1 || len(args) > 2 {
+				return nil, c.ArgErr()
+			}
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				return nil, c.Errf("invalid max_answers '%s'", args[0])
+			}
+			multiCluster.maxAnswers = n
+			if len(args) == 2 {
+				if args[1] != "balanced" {
+					return nil, c.Errf("unknown max_answers mode '%s'", args[1])
+				}
+				multiCluster.maxAnswersBalanced = true
+			}
+		case "subnet":
+			args := c.RemainingArgs()
+			if len(args) < 2 {
+				return nil, c.ArgErr()
+			}
+			_, subnet, err := net.ParseCIDR(args[0])
+			if err != nil {
+				return nil, c.Errf("invalid subnet '%s'", args[0])
+			}
+			multiCluster.addSubnetRule(subnet, args[1:])
+		case "subnet_only":
+			if len(c.RemainingArgs()) != 0 {
+				return nil, c.ArgErr()
+			}
+			multiCluster.subnetOnly = true
+		case "zone_map":
+			args := c.RemainingArgs()
+			if len(args) != 2 {
+				return nil, c.ArgErr()
+			}
+			_, subnet, err := net.ParseCIDR(args[0])
+			if err != nil {
+				return nil, c.Errf("invalid subnet '%s'", args[0])
+			}
+			multiCluster.addZoneRule(subnet, args[1])
+		case "health_probe":
+			args := c.RemainingArgs()
+			if len(args) > 2 {
+				return nil, c.ArgErr()
+			}
+			interval, timeout := defaultProbeInterval, defaultProbeTimeout
+			if len(args) > 0 {
+				d, err := time.ParseDuration(args[0])
+				if err != nil || d <= 0 {
+					return nil, c.Errf("invalid health_probe interval '%s'", args[0])
+				}
+				interval = d
+			}
+			if len(args) > 1 {
+				d, err := time.ParseDuration(args[1])
+				if err != nil || d <= 0 {
+					return nil, c.Errf("invalid health_probe timeout '%s'", args[1])
+				}
+				timeout = d
+			}
+			multiCluster.prober = newProber(interval, timeout)
+		case "cluster_profiles":
+			args := c.RemainingArgs()
+			if len(args) > 1 {
+				return nil, c.ArgErr()
+			}
+			multiCluster.opts.clusterProfiles = true
+			if len(args) == 1 {
+				multiCluster.opts.clusterProfileNamespace = args[0]
+			}
+		case "managed_by":
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return nil, c.ArgErr()
+			}
+			if multiCluster.opts.sliceFilter.managedBy == nil {
+				multiCluster.opts.sliceFilter.managedBy = map[string]struct{}{}
+			}
+			for _, controller := range args {
+				multiCluster.opts.sliceFilter.managedBy[controller] = struct{}{}
+			}
+		case "allowed_clusters":
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return nil, c.ArgErr()
+			}
+			if multiCluster.opts.sliceFilter.clusters == nil {
+				multiCluster.opts.sliceFilter.clusters = map[string]struct{}{}
+			}
+			for _, id := range args {
+				multiCluster.opts.sliceFilter.clusters[id] = struct{}{}
+			}
+		case "label_policy":
+			args := c.RemainingArgs()
+			if len(args) != 1 {
+				return nil, c.ArgErr()
+			}
+			switch args[0] {
+			case "sanitize":
+				multiCluster.opts.labelPolicy = object.LabelSanitize
+			case "reject":
+				multiCluster.opts.labelPolicy = object.LabelReject
+			default:
+				return nil, c.Errf("unknown label_policy '%s'", args[0])
+			}
+		case "cluster_alias":
+			args := c.RemainingArgs()
+			if len(args) != 2 {
+				return nil, c.ArgErr()
+			}
+			if !object.ValidLabel(args[1]) {
+				return nil, c.Errf("cluster alias '%s' is not a valid DNS label", args[1])
+			}
+			if _, ok := multiCluster.aliases.byID[args[0]]; ok {
+				return nil, c.Errf("duplicate alias for cluster '%s'", args[0])
+			}
+			if id, ok := multiCluster.aliases.byAlias[args[1]]; ok {
+				return nil, c.Errf("alias '%s' already used for cluster '%s'", args[1], id)
+			}
+			multiCluster.aliases.add(args[0], args[1])
+		case "cluster_properties":
+			if len(c.RemainingArgs()) != 0 {
+				return nil, c.ArgErr()
+			}
+			multiCluster.opts.clusterProperties = true
+		case "prefer_local":
+			if len(c.RemainingArgs()) != 0 {
+				return nil, c.ArgErr()
+			}
+			multiCluster.preferLocal = true
+		case "mcs_version":
+			args := c.RemainingArgs()
+			if len(args) != 1 {
+				return nil, c.ArgErr()
+			}
+			switch args[0] {
+			case versionAuto, versionV1alpha1, versionV1beta1:
+				multiCluster.mcsVersion = args[0]
+			default:
+				return nil, c.Errf("unsupported mcs_version '%s'", args[0])
+			}
+		case "dns_sd":
+			if len(c.RemainingArgs()) != 0 {
+				return nil, c.ArgErr()
+			}
+			multiCluster.dnsSD = true
+		case "service_info":
+			if len(c.RemainingArgs()) != 0 {
+				return nil, c.ArgErr()
+			}
+			multiCluster.info = true
+		case "status":
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				args = defaultStatusACL
+			}
+			multiCluster.statusACL = []*net.IPNet{}
+			for _, a := range args {
+				_, subnet, err := net.ParseCIDR(a)
+				if err != nil {
+					return nil, c.Errf("invalid subnet '%s'", a)
+				}
+				multiCluster.statusACL = append(multiCluster.statusACL, subnet)
+			}
+		case "debug_http":
+			args := c.RemainingArgs()
+			if len(args) > 1 {
+				return nil, c.ArgErr()
+			}
+			addr := defaultDebugAddress
+			if len(args) == 1 {
+				addr = args[0]
+			}
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				return nil, c.Errf("invalid debug_http address '%s': %v", addr, err)
+			}
+			multiCluster.debug = newDebugServer(addr, multiCluster)
+		case "upstream":
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				// the default, resolve through the server itself
+				multiCluster.Upstream = upstream.New()
+				break
+			}
+			addrs, err := parse.HostPortOrFile(args...)
+			if err != nil {
+				return nil, err
+			}
+			multiCluster.Upstream = newForwarder(addrs)
 		case "noendpoints":
 			if len(c.RemainingArgs()) != 0 {
 				return nil, c.ArgErr()
@@ -79,6 +380,47 @@ func ParseStanza(c *caddy.Controller) (*MultiCluster, error) {
 			return nil, c.Errf("unknown property '%s'", c.Val())
 		}
 	}
+	multiCluster.resolveAliases()
+
+	backends := 0
+	for _, set := range []bool{multiCluster.ClientConfig != nil, multiCluster.file != "", len(multiCluster.members) > 0} {
+		if set {
+			backends++
+		}
+	}
+	if backends > 1 {
+		return nil, c.Err("kubeconfig, file and member are mutually exclusive")
+	}
+	if len(multiCluster.clusterSetIPs) > 0 && len(multiCluster.members) == 0 {
+		return nil, c.Err("clusterset_ip requires member")
+	}
+	if multiCluster.opts.drainName != "" && (multiCluster.file != "" || len(multiCluster.members) > 0) {
+		return nil, c.Err("drain_configmap can't be used with file or member")
+	}
+	if multiCluster.opts.clusterProfiles && (multiCluster.file != "" || len(multiCluster.members) > 0) {
+		return nil, c.Err("cluster_profiles can't be used with file or member")
+	}
+	if multiCluster.opts.clusterProperties && (multiCluster.file != "" || len(multiCluster.members) > 0) {
+		return nil, c.Err("cluster_properties can't be used with file or member")
+	}
+	if multiCluster.preferLocal && !multiCluster.opts.clusterProperties {
+		return nil, c.Err("prefer_local requires cluster_properties")
+	}
+	if multiCluster.opts.sliceFilter.enabled() && (multiCluster.file != "" || len(multiCluster.members) > 0) {
+		return nil, c.Err("managed_by and allowed_clusters can't be used with file or member")
+	}
 
 	return multiCluster, nil
 }
+
+// clientConfig returns the client config for the KUBECONFIG [CONTEXT] arguments.
+func clientConfig(args []string) clientcmd.ClientConfig {
+	overrides := &clientcmd.ConfigOverrides{}
+	if len(args) == 2 {
+		overrides.CurrentContext = args[1]
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: args[0]},
+		overrides,
+	)
+}
@@ -40,6 +40,274 @@ func TestParseStanza(t *testing.T) {
 			2,
 			fall.Root,
 		},
+		{
+			`multicluster clusterset.local {
+    file /etc/coredns/clusterset.yaml 10s
+}`,
+			false,
+			"",
+			1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    member c1 /etc/c1.kubeconfig
+    member c2 /etc/c2.kubeconfig ctx
+    clusterset_ip testns/svc1 10.0.0.1 fd00::1
+}`,
+			false,
+			"",
+			1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    drain c1 c2
+    drain_configmap kube-system/multicluster
+    drain_explicit
+    weight c1 90
+    weight c2 10
+    order round_robin
+    max_answers 20 balanced
+    subnet 10.0.0.0/8 c1
+    subnet fd00::/8 c1 c2
+    subnet_only
+    zone_map 10.1.0.0/16 zone-a
+    health_probe 5s 500ms
+    cluster_profiles fleet
+    managed_by mcs-controller.example.com
+    allowed_clusters c1 c2
+    label_policy reject
+    cluster_alias 7f3c2a9e-5b1d-4c8e-9a6f-0d2e4b8c1a3f east
+    cluster_properties
+    prefer_local
+    mcs_version v1beta1
+    upstream 10.0.0.10 10.0.0.11:5353
+    dns_sd
+    service_info
+    status 10.0.0.0/8
+    debug_http localhost:9154
+}`,
+			false,
+			"",
+			1,
+			fall.Zero,
+		},
+		// negative
+		{
+			`multicluster clusterset.local {
+    file /etc/coredns/clusterset.yaml 0s
+}`,
+			true,
+			"must be positive",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    kubeconfig /etc/kubeconfig
+    file /etc/coredns/clusterset.yaml
+}`,
+			true,
+			"mutually exclusive",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    member c1 /etc/c1.kubeconfig
+    member c1 /etc/c2.kubeconfig
+}`,
+			true,
+			"duplicate member",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    member East_1 /etc/c1.kubeconfig
+}`,
+			true,
+			"not a valid DNS label",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    label_policy drop
+}`,
+			true,
+			"unknown label_policy",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    cluster_alias c1 east
+    cluster_alias c2 east
+}`,
+			true,
+			"already used for cluster",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    prefer_local
+    mcs_version v1beta1
+}`,
+			true,
+			"prefer_local requires cluster_properties",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    debug_http 9154
+}`,
+			true,
+			"invalid debug_http address",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    status 10.0.0.0
+}`,
+			true,
+			"invalid subnet",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    upstream not-an-address
+}`,
+			true,
+			"not an IP address or file",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    mcs_version v2
+}`,
+			true,
+			"unsupported mcs_version",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    member c1 /etc/c1.kubeconfig
+    clusterset_ip testns/svc1 not-an-ip
+}`,
+			true,
+			"invalid ClusterSetIP",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    clusterset_ip testns/svc1 10.0.0.1
+}`,
+			true,
+			"requires member",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    drain_configmap multicluster
+}`,
+			true,
+			"expected NAMESPACE/NAME",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    weight c1 -1
+}`,
+			true,
+			"invalid weight",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    order sorted
+}`,
+			true,
+			"unknown order",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    max_answers 0
+}`,
+			true,
+			"invalid max_answers",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    max_answers 20 even
+}`,
+			true,
+			"unknown max_answers mode",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    subnet 10.0.0.0 c1
+}`,
+			true,
+			"invalid subnet",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    zone_map 10.1.0.0/16
+}`,
+			true,
+			"Wrong argument count",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    health_probe 5s -1s
+}`,
+			true,
+			"invalid health_probe timeout",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    member c1 /etc/c1.kubeconfig
+    cluster_profiles
+}`,
+			true,
+			"cluster_profiles can't be used with file or member",
+			-1,
+			fall.Zero,
+		},
+		{
+			`multicluster clusterset.local {
+    file /etc/coredns/clusterset.yaml
+    allowed_clusters c1
+}`,
+			true,
+			"can't be used with file or member",
+			-1,
+			fall.Zero,
+		},
 	}
 
 	for i, test := range tests {
@@ -0,0 +1,117 @@
+package multicluster
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// statusName is the CHAOS class name answered with the status of the plugin.
+const statusName = "status." + pluginName + "."
+
+// defaultStatusACL only allows queries for the status from the pod itself.
+var defaultStatusACL = []string{"127.0.0.0/8", "::1/128"}
+
+// isStatusQuery returns true if state is a CHAOS TXT query for the status of the plugin.
+func isStatusQuery(state request.Request) bool {
+	return state.QClass() == dns.ClassCHAOS && state.QType() == dns.TypeTXT && state.Name() == statusName
+}
+
+// statusAllowed returns true if the client of state may query the status. The address the query
+// came from is used, not the client subnet.
+func (m *MultiCluster) statusAllowed(state request.Request) bool {
+	ip := net.ParseIP(state.IP())
+	if ip == nil {
+		return false
+	}
+	for _, n := range m.statusACL {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// backend returns the name of the source of the ServiceImports.
+func (m *MultiCluster) backend() string {
+	switch {
+	case m.file != "":
+		return "file"
+	case len(m.members) > 0:
+		return "member"
+	}
+	return "kubernetes"
+}
+
+// pluginStatus is the state of the plugin.
+type pluginStatus struct {
+	Synced         bool     `json:"synced"`
+	Modified       string   `json:"modified"`
+	Imports        int      `json:"imports"`
+	EndpointSlices int      `json:"endpointslices"`
+	Zones          []string `json:"zones"`
+	Backend        string   `json:"backend"`
+	ClusterID      string   `json:"clusterID,omitempty"`
+	ClusterSetID   string   `json:"clusterSetID,omitempty"`
+}
+
+// pluginStatus returns the state of the plugin.
+func (m *MultiCluster) pluginStatus() pluginStatus {
+	s := pluginStatus{
+		Synced:         m.controller.HasSynced(),
+		Modified:       "never",
+		Imports:        len(m.controller.ServiceList()),
+		EndpointSlices: len(m.controller.EndpointsList()),
+		Zones:          m.Zones,
+		Backend:        m.backend(),
+	}
+	if unix := m.controller.Modified(); unix > 0 {
+		s.Modified = time.Unix(unix, 0).UTC().Format(time.RFC3339)
+	}
+	s.ClusterID, s.ClusterSetID = m.identity.get()
+	return s
+}
+
+// status returns the TXT records describing the state of the plugin.
+func (m *MultiCluster) status(state request.Request) []dns.RR {
+	s := m.pluginStatus()
+	text := []string{
+		"synced=" + strconv.FormatBool(s.Synced),
+		"modified=" + s.Modified,
+		"imports=" + strconv.Itoa(s.Imports),
+		"endpointslices=" + strconv.Itoa(s.EndpointSlices),
+		"zones=" + strings.Join(s.Zones, ","),
+		"backend=" + s.Backend,
+	}
+	if s.ClusterID != "" {
+		text = append(text, "cluster-id="+s.ClusterID)
+	}
+	if s.ClusterSetID != "" {
+		text = append(text, "clusterset-id="+s.ClusterSetID)
+	}
+
+	records := make([]dns.RR, len(text))
+	for i, t := range text {
+		records[i] = &dns.TXT{Hdr: dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeTXT, Class: dns.ClassCHAOS, Ttl: 0}, Txt: []string{t}}
+	}
+	return records
+}
+
+// serveStatus answers the status query of state, refusing clients not allowed by the ACL.
+func (m *MultiCluster) serveStatus(w dns.ResponseWriter, state request.Request) (int, error) {
+	message := new(dns.Msg)
+	message.SetReply(state.Req)
+	if !m.statusAllowed(state) {
+		message.Rcode = dns.RcodeRefused
+		w.WriteMsg(message)
+		return dns.RcodeRefused, nil
+	}
+	message.Authoritative = true
+	message.Answer = m.status(state)
+	w.WriteMsg(message)
+	return dns.RcodeSuccess, nil
+}
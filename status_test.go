@@ -0,0 +1,123 @@
+package multicluster
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/miekg/dns"
+)
+
+func statusQuery() *dns.Msg {
+	r := new(dns.Msg)
+	r.SetQuestion(statusName, dns.TypeTXT)
+	r.Question[0].Qclass = dns.ClassCHAOS
+	return r
+}
+
+func TestStatusServeDNS(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	m.identity.set(clusterIDProperty, "7f3c2a9e")
+	_, acl, _ := net.ParseCIDR("10.240.0.0/16")
+	m.statusACL = []*net.IPNet{acl}
+
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := m.ServeDNS(context.TODO(), w, statusQuery()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if w.Msg.Rcode != dns.RcodeSuccess {
+		t.Fatalf("Expected NOERROR, got %s", dns.RcodeToString[w.Msg.Rcode])
+	}
+
+	got := map[string]bool{}
+	for _, rr := range w.Msg.Answer {
+		txt := rr.(*dns.TXT)
+		if txt.Hdr.Class != dns.ClassCHAOS {
+			t.Errorf("Expected a CHAOS record, got %s", rr)
+		}
+		got[txt.Txt[0]] = true
+	}
+	for _, expected := range []string{
+		"synced=true",
+		"modified=" + time.Unix(3, 0).UTC().Format(time.RFC3339),
+		"imports=" + strconv.Itoa(len(controllerMock2{}.ServiceList())),
+		"endpointslices=" + strconv.Itoa(len(controllerMock2{}.EndpointsList())),
+		"zones=cluster.local.",
+		"backend=kubernetes",
+		"cluster-id=7f3c2a9e",
+	} {
+		if !got[expected] {
+			t.Errorf("Expected %q in the status, got %v", expected, w.Msg.Answer)
+		}
+	}
+	if len(got) != 7 {
+		t.Errorf("Expected 7 records, got %v", w.Msg.Answer)
+	}
+}
+
+func TestStatusACL(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	for _, s := range defaultStatusACL {
+		_, n, _ := net.ParseCIDR(s)
+		m.statusACL = append(m.statusACL, n)
+	}
+
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := m.ServeDNS(context.TODO(), w, statusQuery()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if w.Msg.Rcode != dns.RcodeRefused || len(w.Msg.Answer) != 0 {
+		t.Errorf("Expected a refusal, got %v", w.Msg)
+	}
+
+	w = dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: "127.0.0.1"})
+	if _, err := m.ServeDNS(context.TODO(), w, statusQuery()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if w.Msg.Rcode != dns.RcodeSuccess || len(w.Msg.Answer) == 0 {
+		t.Errorf("Expected the status, got %v", w.Msg)
+	}
+}
+
+func TestStatusDisabled(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+
+	w := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: "127.0.0.1"})
+	if _, err := m.ServeDNS(context.TODO(), w, statusQuery()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if w.Msg != nil {
+		t.Errorf("Expected the query to be passed on without status, got %v", w.Msg)
+	}
+}
+
+func TestStatusNoEndpoints(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = newControllerWithoutEndpoints(t)
+	_, acl, _ := net.ParseCIDR("10.240.0.0/16")
+	m.statusACL = []*net.IPNet{acl}
+
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := m.ServeDNS(context.TODO(), w, statusQuery()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	found := false
+	for _, rr := range w.Msg.Answer {
+		if rr.(*dns.TXT).Txt[0] == "endpointslices=0" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected no EndpointSlices in the status, got %v", w.Msg.Answer)
+	}
+}
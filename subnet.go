@@ -0,0 +1,157 @@
+package multicluster
+
+import (
+	"net"
+	"sort"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// subnetRule maps clients in a subnet to their preferred clusters.
+type subnetRule struct {
+	subnet   *net.IPNet
+	clusters map[string]struct{}
+}
+
+// addSubnetRule adds a rule for subnet, keeping the rules ordered from the longest to the shortest prefix.
+func (m *MultiCluster) addSubnetRule(subnet *net.IPNet, clusters []string) {
+	rule := subnetRule{subnet: subnet, clusters: map[string]struct{}{}}
+	for _, c := range clusters {
+		rule.clusters[c] = struct{}{}
+	}
+	m.subnets = append(m.subnets, rule)
+	sort.SliceStable(m.subnets, func(i, j int) bool {
+		oi, _ := m.subnets[i].subnet.Mask.Size()
+		oj, _ := m.subnets[j].subnet.Mask.Size()
+		return oi > oj
+	})
+}
+
+// matchSubnet returns the rule with the longest prefix containing client.
+func (m *MultiCluster) matchSubnet(client net.IP) (subnetRule, bool) {
+	if client == nil {
+		return subnetRule{}, false
+	}
+	for _, rule := range m.subnets {
+		if rule.subnet.Contains(client) {
+			return rule, true
+		}
+	}
+	return subnetRule{}, false
+}
+
+// preferCandidates keeps the candidates of the clusters preferred by the subnet of client. If none of the
+// preferred clusters has candidates, all candidates are kept, unless m.subnetOnly is set. Clients outside
+// of all subnets get all candidates.
+func (m *MultiCluster) preferCandidates(client net.IP, candidates []candidate) []candidate {
+	rule, ok := m.matchSubnet(client)
+	if !ok {
+		return candidates
+	}
+	preferred := candidates[:0:0]
+	for _, c := range candidates {
+		if _, ok := rule.clusters[c.cluster]; ok {
+			preferred = append(preferred, c)
+		}
+	}
+	if len(preferred) == 0 && !m.subnetOnly {
+		return candidates
+	}
+	return preferred
+}
+
+// clientSubnet returns the EDNS0 Client Subnet option of req, if any.
+func clientSubnet(req *dns.Msg) *dns.EDNS0_SUBNET {
+	if req == nil {
+		return nil
+	}
+	o := req.IsEdns0()
+	if o == nil {
+		return nil
+	}
+	for _, opt := range o.Option {
+		if ecs, ok := opt.(*dns.EDNS0_SUBNET); ok {
+			return ecs
+		}
+	}
+	return nil
+}
+
+// subnetWriter echoes the EDNS0 Client Subnet option of the query in the response, with the scope
+// the answer is valid for.
+type subnetWriter struct {
+	dns.ResponseWriter
+	ecs *dns.EDNS0_SUBNET
+}
+
+// newSubnetWriter returns a writer echoing ecs with a scope of 0, valid for all clients, until the
+// answer turns out to depend on the client.
+func newSubnetWriter(w dns.ResponseWriter, ecs *dns.EDNS0_SUBNET) *subnetWriter {
+	echo := &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        ecs.Family,
+		SourceNetmask: ecs.SourceNetmask,
+		Address:       ecs.Address,
+	}
+	return &subnetWriter{ResponseWriter: w, ecs: echo}
+}
+
+// nextWriter returns the writer to hand to the next plugin: w without its subnetWriter, so the
+// EDNS0 Client Subnet option the next plugin writes is kept as is.
+func nextWriter(w dns.ResponseWriter) dns.ResponseWriter {
+	if sw, ok := w.(*subnetWriter); ok {
+		return sw.ResponseWriter
+	}
+	return w
+}
+
+// narrowScope makes the answer of state valid for at most the clients sharing the first ones bits
+// of the client address, or the subnet of the query if ones is negative. It does nothing if the
+// query has no EDNS0 Client Subnet option.
+func narrowScope(state request.Request, ones int) {
+	w, ok := state.W.(*subnetWriter)
+	if !ok {
+		return
+	}
+	if ones < 0 {
+		ones = int(w.ecs.SourceNetmask)
+	}
+	if uint8(ones) > w.ecs.SourceScope {
+		w.ecs.SourceScope = uint8(ones)
+	}
+}
+
+// subnetScope returns the prefix length the subnet rules give the same answer for around client: the
+// prefix of the matching rule, unless a longer rule lies within it. If there is no such prefix, e.g.
+// because no rule matches, it returns -1 for the subnet of the query.
+func (m *MultiCluster) subnetScope(client net.IP) int {
+	rule, ok := m.matchSubnet(client)
+	if !ok {
+		return -1
+	}
+	ones, _ := rule.subnet.Mask.Size()
+	for _, r := range m.subnets {
+		if o, _ := r.subnet.Mask.Size(); o > ones && rule.subnet.Contains(r.subnet.IP) {
+			return -1
+		}
+	}
+	return ones
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *subnetWriter) WriteMsg(res *dns.Msg) error {
+	o := res.IsEdns0()
+	if o == nil {
+		res.SetEdns0(dns.DefaultMsgSize, false)
+		o = res.IsEdns0()
+	}
+	options := o.Option[:0]
+	for _, opt := range o.Option {
+		if opt.Option() != dns.EDNS0SUBNET {
+			options = append(options, opt)
+		}
+	}
+	o.Option = append(options, w.ecs)
+	return w.ResponseWriter.WriteMsg(res)
+}
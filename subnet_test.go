@@ -0,0 +1,234 @@
+package multicluster
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/fall"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+func mustParseCIDR(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+	_, subnet, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return subnet
+}
+
+func TestPreferCandidates(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.addSubnetRule(mustParseCIDR(t, "10.0.0.0/8"), []string{"a"})
+	m.addSubnetRule(mustParseCIDR(t, "10.1.0.0/16"), []string{"b", "c"})
+	m.addSubnetRule(mustParseCIDR(t, "192.168.0.0/16"), []string{"d"})
+
+	tests := []struct {
+		client   string
+		clusters []string
+	}{
+		{"10.2.0.1", []string{"a"}},
+		{"10.1.0.1", []string{"b", "c"}},
+		{"172.16.0.1", []string{"a", "b", "c"}},
+		// no preferred cluster has endpoints, keep all
+		{"192.168.0.1", []string{"a", "b", "c"}},
+	}
+	for i, tc := range tests {
+		clusters := candidateClusters(m.preferCandidates(net.ParseIP(tc.client), weightCandidates()))
+		if len(clusters) != len(tc.clusters) {
+			t.Errorf("Test %d: expected clusters %v, got %v", i, tc.clusters, clusters)
+			continue
+		}
+		for j := range clusters {
+			if clusters[j] != tc.clusters[j] {
+				t.Errorf("Test %d: expected clusters %v, got %v", i, tc.clusters, clusters)
+			}
+		}
+	}
+
+	m.subnetOnly = true
+	if selected := m.preferCandidates(net.ParseIP("192.168.0.1"), weightCandidates()); len(selected) != 0 {
+		t.Errorf("Expected no candidates with subnet_only, got %v", selected)
+	}
+}
+
+func TestClientIPSubnet(t *testing.T) {
+	r := new(dns.Msg)
+	r.SetQuestion("hdls1.testns.svc.cluster.local.", dns.TypeA)
+	state := request.Request{W: &test.ResponseWriter{}, Req: r}
+	if ip := clientIP(state); !ip.Equal(net.ParseIP("10.240.0.1")) {
+		t.Errorf("Expected the source address, got %s", ip)
+	}
+
+	setClientSubnet(r, "192.168.1.0", 24)
+	if ip := clientIP(state); !ip.Equal(net.ParseIP("192.168.1.0")) {
+		t.Errorf("Expected the client subnet address, got %s", ip)
+	}
+}
+
+func setClientSubnet(r *dns.Msg, addr string, netmask uint8) {
+	r.SetEdns0(dns.DefaultMsgSize, false)
+	o := r.IsEdns0()
+	o.Option = append(o.Option, &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        1,
+		SourceNetmask: netmask,
+		Address:       net.ParseIP(addr).To4(),
+	})
+}
+
+func TestSubnetServeDNS(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	m.addSubnetRule(mustParseCIDR(t, "192.168.0.0/16"), []string{"clusterid"})
+	m.addSubnetRule(mustParseCIDR(t, "172.16.0.0/12"), []string{"other"})
+	m.subnetOnly = true
+	ctx := context.TODO()
+
+	tests := []struct {
+		subnet string
+		rcode  int
+		answer int
+		scope  uint8
+	}{
+		{"192.168.1.0", dns.RcodeSuccess, 4, 16},
+		{"172.16.1.0", dns.RcodeNameError, 0, 12},
+		// outside of all subnets, the answer is only valid for the subnet of the query
+		{"10.1.1.0", dns.RcodeSuccess, 4, 24},
+	}
+	for i, tc := range tests {
+		r := new(dns.Msg)
+		r.SetQuestion("hdls1.testns.svc.cluster.local.", dns.TypeA)
+		setClientSubnet(r, tc.subnet, 24)
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+
+		if _, err := m.ServeDNS(ctx, w, r); err != nil {
+			t.Fatalf("Test %d: expected no error, got %v", i, err)
+		}
+		resp := w.Msg
+		if resp.Rcode != tc.rcode || len(resp.Answer) != tc.answer {
+			t.Errorf("Test %d: expected rcode %d with %d answers, got %d with %d", i, tc.rcode, tc.answer, resp.Rcode, len(resp.Answer))
+		}
+		ecs := clientSubnet(resp)
+		if ecs == nil {
+			t.Fatalf("Test %d: expected the client subnet to be echoed", i)
+		}
+		if ecs.SourceScope != tc.scope || ecs.SourceNetmask != 24 {
+			t.Errorf("Test %d: expected scope %d, got %d/%d", i, tc.scope, ecs.SourceNetmask, ecs.SourceScope)
+		}
+	}
+}
+
+func TestSubnetScope(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.addSubnetRule(mustParseCIDR(t, "10.0.0.0/8"), []string{"c1"})
+	m.addSubnetRule(mustParseCIDR(t, "10.1.0.0/16"), []string{"c2"})
+	m.addSubnetRule(mustParseCIDR(t, "192.168.0.0/16"), []string{"c2"})
+
+	tests := []struct {
+		client string
+		scope  int
+	}{
+		{"10.1.3.0", 16},
+		// the /8 isn't answered alike, as it holds the /16
+		{"10.2.3.0", -1},
+		{"192.168.1.0", 16},
+		{"172.16.1.0", -1},
+	}
+	for i, tc := range tests {
+		if scope := m.subnetScope(net.ParseIP(tc.client)); scope != tc.scope {
+			t.Errorf("Test %d: expected scope %d for %s, got %d", i, tc.scope, tc.client, scope)
+		}
+	}
+}
+
+func TestSubnetScopeServeDNS(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	m.addSubnetRule(mustParseCIDR(t, "10.0.0.0/8"), []string{"other"})
+	m.addSubnetRule(mustParseCIDR(t, "10.1.0.0/16"), []string{"clusterid"})
+	ctx := context.TODO()
+
+	scope := func(qname, client string) uint8 {
+		t.Helper()
+		r := new(dns.Msg)
+		r.SetQuestion(qname, dns.TypeA)
+		setClientSubnet(r, client, 24)
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := m.ServeDNS(ctx, w, r); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		ecs := clientSubnet(w.Msg)
+		if ecs == nil {
+			t.Fatal("Expected the client subnet to be echoed")
+		}
+		return ecs.SourceScope
+	}
+
+	if s := scope("hdls1.testns.svc.cluster.local.", "10.1.3.0"); s != 16 {
+		t.Errorf("Expected scope 16 in the nested subnet, got %d", s)
+	}
+	if s := scope("hdls1.testns.svc.cluster.local.", "10.2.3.0"); s != 24 {
+		t.Errorf("Expected the scope of the query around the nested subnet, got %d", s)
+	}
+	// the answer doesn't depend on the client
+	if s := scope("svc1.testns.svc.cluster.local.", "10.2.3.0"); s != 0 {
+		t.Errorf("Expected scope 0 for a ClusterSetIP service, got %d", s)
+	}
+	if s := scope("172-0-0-2.clusterid.hdls1.testns.svc.cluster.local.", "10.2.3.0"); s != 0 {
+		t.Errorf("Expected scope 0 for an endpoint, got %d", s)
+	}
+	// weights hash the whole client address
+	m.weights = map[string]int{"clusterid": 1}
+	if s := scope("hdls1.testns.svc.cluster.local.", "10.1.3.0"); s != 24 {
+		t.Errorf("Expected the scope of the query with weights, got %d", s)
+	}
+	m.weights = nil
+	m.order = orderHash
+	if s := scope("svc1.testns.svc.cluster.local.", "10.2.3.0"); s != 0 {
+		t.Errorf("Expected scope 0 for a single address, got %d", s)
+	}
+	if s := scope("hdls1.testns.svc.cluster.local.", "10.1.3.0"); s != 24 {
+		t.Errorf("Expected the scope of the query with the hash order, got %d", s)
+	}
+}
+
+func TestSubnetScopeFallthrough(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &controllerMock2{}
+	m.Fall = fall.Root
+	m.dnsSD = true
+	m.Next = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		res := new(dns.Msg)
+		res.SetReply(r)
+		res.SetEdns0(dns.DefaultMsgSize, false)
+		ecs := *clientSubnet(r)
+		ecs.SourceScope = 24
+		res.IsEdns0().Option = append(res.IsEdns0().Option, &ecs)
+		w.WriteMsg(res)
+		return dns.RcodeSuccess, nil
+	})
+
+	for _, qname := range []string{
+		"nosvc.testns.svc.cluster.local.",
+		"_nosvc._tcp.testns.svc.cluster.local.",
+	} {
+		r := new(dns.Msg)
+		r.SetQuestion(qname, dns.TypeA)
+		setClientSubnet(r, "10.1.2.0", 24)
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := m.ServeDNS(context.TODO(), w, r); err != nil {
+			t.Fatalf("%s: expected no error, got %v", qname, err)
+		}
+		if ecs := clientSubnet(w.Msg); ecs == nil || ecs.SourceScope != 24 {
+			t.Errorf("%s: expected the scope of the next plugin, got %v", qname, ecs)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package multicluster
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/coredns/coredns/request"
+	"github.com/coredns/multicluster/object"
+	"github.com/miekg/dns"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// alpnProtocol is the ALPN protocol id of an appProtocol, and whether it runs without TLS.
+type alpnProtocol struct {
+	id        string
+	cleartext bool
+}
+
+// appProtocolALPN maps the appProtocol of a port to its ALPN protocol.
+var appProtocolALPN = map[string]alpnProtocol{
+	"http":              {id: "http/1.1", cleartext: true},
+	"https":             {id: "http/1.1"},
+	"h2":                {id: "h2"},
+	"h2c":               {id: "h2c", cleartext: true},
+	"kubernetes.io/h2c": {id: "h2c", cleartext: true},
+	"h3":                {id: "h3"},
+}
+
+// serviceBindings returns the SVCB or HTTPS records for the service of state. ClusterSetIP services
+// get a record for every port with a known appProtocol, services with an external name an alias to it.
+func (m *MultiCluster) serviceBindings(ctx context.Context, state request.Request) ([]dns.RR, error) {
+	// Do a fake A lookup, so we can distinguish between NODATA and NXDOMAIN
+	fake := state.NewWithQuestion(state.QName(), dns.TypeA)
+	fake.Zone = state.Zone
+	if _, err := m.Records(ctx, fake, false); err != nil {
+		return nil, err
+	}
+
+	r, _ := parseRequest(state.Name(), state.Zone)
+	if r.service == "" || r.port != "" || r.endpoint != "" {
+		return nil, nil
+	}
+
+	var records []dns.RR
+	for _, svc := range m.controller.SvcIndex(object.ServiceKey(r.service, r.namespace)) {
+		switch {
+		case svc.ExternalName != "":
+			records = append(records, m.newBinding(state, 0, svc.ExternalName, nil))
+		case svc.Type == mcs.ClusterSetIP:
+			records = append(records, m.serviceModeBindings(state, svc)...)
+		}
+	}
+	return records, nil
+}
+
+// serviceModeBindings returns a record for every port of the ClusterSetIP service svc with a known
+// appProtocol, hinting at its ClusterSetIPs. Ports are preferred in the order they are listed. HTTPS
+// records imply TLS, so they leave out the ports of cleartext protocols.
+func (m *MultiCluster) serviceModeBindings(state request.Request, svc *object.ServiceImport) []dns.RR {
+	var v4, v6 []net.IP
+	for _, ip := range svc.ClusterIPs {
+		addr := net.ParseIP(ip)
+		switch {
+		case addr == nil:
+		case addr.To4() != nil:
+			v4 = append(v4, addr.To4())
+		default:
+			v6 = append(v6, addr)
+		}
+	}
+
+	var records []dns.RR
+	for _, p := range svc.Ports {
+		if p.AppProtocol == nil {
+			continue
+		}
+		alpn, ok := appProtocolALPN[strings.ToLower(*p.AppProtocol)]
+		if !ok {
+			continue
+		}
+		if alpn.cleartext && state.QType() == dns.TypeHTTPS {
+			continue
+		}
+		values := []dns.SVCBKeyValue{&dns.SVCBAlpn{Alpn: []string{alpn.id}}}
+		if state.QType() == dns.TypeHTTPS && alpn.id != "http/1.1" {
+			// clients must not assume http/1.1, the default of HTTPS records
+			values = append(values, &dns.SVCBNoDefaultAlpn{})
+		}
+		values = append(values, &dns.SVCBPort{Port: uint16(p.Port)})
+		if len(v4) > 0 {
+			values = append(values, &dns.SVCBIPv4Hint{Hint: v4})
+		}
+		if len(v6) > 0 {
+			values = append(values, &dns.SVCBIPv6Hint{Hint: v6})
+		}
+		records = append(records, m.newBinding(state, uint16(len(records)+1), ".", values))
+	}
+	return records
+}
+
+// newBinding returns an SVCB or HTTPS record, depending on the type of the query in state.
+func (m *MultiCluster) newBinding(state request.Request, priority uint16, target string, values []dns.SVCBKeyValue) dns.RR {
+	svcb := dns.SVCB{
+		Hdr:      dns.RR_Header{Name: state.QName(), Rrtype: state.QType(), Class: dns.ClassINET, Ttl: m.ttl},
+		Priority: priority,
+		Target:   target,
+		Value:    values,
+	}
+	if state.QType() == dns.TypeHTTPS {
+		return &dns.HTTPS{SVCB: svcb}
+	}
+	return &svcb
+}
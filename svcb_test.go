@@ -0,0 +1,132 @@
+package multicluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/multicluster/object"
+	"github.com/miekg/dns"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+// svcbControllerMock adds a ClusterSetIP service with appProtocols to externalControllerMock.
+type svcbControllerMock struct {
+	externalControllerMock
+}
+
+func appProtocol(p string) *string { return &p }
+
+var svcbSvcIndex = map[string][]*object.ServiceImport{
+	"web1.testns": {{
+		Name:       "web1",
+		Namespace:  "testns",
+		Index:      "web1.testns",
+		Type:       mcs.ClusterSetIP,
+		ClusterIPs: []string{"10.0.0.20", "fd00::20"},
+		Ports: []mcs.ServicePort{
+			{Name: "http", Protocol: "TCP", Port: 80, AppProtocol: appProtocol("http")},
+			{Name: "grpc", Protocol: "TCP", Port: 9090, AppProtocol: appProtocol("kubernetes.io/h2c")},
+			{Name: "https", Protocol: "TCP", Port: 443, AppProtocol: appProtocol("https")},
+			{Name: "h2", Protocol: "TCP", Port: 8443, AppProtocol: appProtocol("h2")},
+			{Name: "metrics", Protocol: "TCP", Port: 9100},
+		},
+	}},
+}
+
+func (c svcbControllerMock) SvcIndex(s string) []*object.ServiceImport {
+	if svcs, ok := svcbSvcIndex[s]; ok {
+		return svcs
+	}
+	return c.externalControllerMock.SvcIndex(s)
+}
+
+var svcbTestCases = []test.Case{
+	// a record for every port with a known TLS appProtocol
+	{
+		Qname: "web1.testns.svc.cluster.local.", Qtype: dns.TypeHTTPS,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			newRR(`web1.testns.svc.cluster.local.	5	IN	HTTPS	1 . alpn="http/1.1" port="443" ipv4hint="10.0.0.20" ipv6hint="fd00::20"`),
+			newRR(`web1.testns.svc.cluster.local.	5	IN	HTTPS	2 . alpn="h2" no-default-alpn port="8443" ipv4hint="10.0.0.20" ipv6hint="fd00::20"`),
+		},
+	},
+	// SVCB records include cleartext protocols
+	{
+		Qname: "web1.testns.svc.cluster.local.", Qtype: dns.TypeSVCB,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			newRR(`web1.testns.svc.cluster.local.	5	IN	SVCB	1 . alpn="http/1.1" port="80" ipv4hint="10.0.0.20" ipv6hint="fd00::20"`),
+			newRR(`web1.testns.svc.cluster.local.	5	IN	SVCB	2 . alpn="h2c" port="9090" ipv4hint="10.0.0.20" ipv6hint="fd00::20"`),
+			newRR(`web1.testns.svc.cluster.local.	5	IN	SVCB	3 . alpn="http/1.1" port="443" ipv4hint="10.0.0.20" ipv6hint="fd00::20"`),
+			newRR(`web1.testns.svc.cluster.local.	5	IN	SVCB	4 . alpn="h2" port="8443" ipv4hint="10.0.0.20" ipv6hint="fd00::20"`),
+		},
+	},
+	// an alias to the external name
+	{
+		Qname: "ext1.testns.svc.cluster.local.", Qtype: dns.TypeHTTPS,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			newRR("ext1.testns.svc.cluster.local.	5	IN	HTTPS	0 lb.example.org."),
+		},
+	},
+	// NODATA without appProtocols and for headless services
+	{
+		Qname: "svc1.testns.svc.cluster.local.", Qtype: dns.TypeHTTPS,
+		Rcode: dns.RcodeSuccess,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+	{
+		Qname: "hdls1.testns.svc.cluster.local.", Qtype: dns.TypeHTTPS,
+		Rcode: dns.RcodeSuccess,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+	// NXDOMAIN for unknown services
+	{
+		Qname: "nosvc.testns.svc.cluster.local.", Qtype: dns.TypeHTTPS,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+}
+
+// newRR returns the record of s, as the test package has no helpers for SVCB and HTTPS records.
+func newRR(s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+func TestServiceBindingsServeDNS(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.controller = &svcbControllerMock{}
+	m.Upstream = upstreamMock{}
+	m.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	ctx := context.TODO()
+
+	for i, tc := range svcbTestCases {
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := m.ServeDNS(ctx, w, tc.Msg()); err != nil {
+			t.Errorf("Test %d expected no error, got %v", i, err)
+			continue
+		}
+		if err := test.SortAndCheck(w.Msg, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+			continue
+		}
+		// SortAndCheck only compares the headers of SVCB and HTTPS records
+		for j, rr := range tc.Answer {
+			if got := w.Msg.Answer[j].String(); got != rr.String() {
+				t.Errorf("Test %d: expected answer %q, got %q", i, rr, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package multicluster
+
+import (
+	"net"
+	"sort"
+)
+
+// zoneRule maps clients in a subnet to a topology zone.
+type zoneRule struct {
+	subnet *net.IPNet
+	zone   string
+}
+
+// addZoneRule adds a rule for subnet, keeping the rules ordered from the longest to the shortest prefix.
+func (m *MultiCluster) addZoneRule(subnet *net.IPNet, zone string) {
+	m.zones = append(m.zones, zoneRule{subnet: subnet, zone: zone})
+	sort.SliceStable(m.zones, func(i, j int) bool {
+		oi, _ := m.zones[i].subnet.Mask.Size()
+		oj, _ := m.zones[j].subnet.Mask.Size()
+		return oi > oj
+	})
+}
+
+// clientZone returns the zone of client, or an empty string if it isn't known.
+func (m *MultiCluster) clientZone(client net.IP) string {
+	if client == nil {
+		return ""
+	}
+	for _, rule := range m.zones {
+		if rule.subnet.Contains(client) {
+			return rule.zone
+		}
+	}
+	return ""
+}
+
+// zoneCandidates keeps the candidates serving the zone of client, based on the topology of the
+// EndpointSlices. If the zone of client isn't known or no candidate serves it, all candidates are kept.
+func (m *MultiCluster) zoneCandidates(client net.IP, candidates []candidate) []candidate {
+	zone := m.clientZone(client)
+	if zone == "" {
+		return candidates
+	}
+	local := candidates[:0:0]
+	for _, c := range candidates {
+		if c.topology.InZone(zone) {
+			local = append(local, c)
+		}
+	}
+	if len(local) == 0 {
+		return candidates
+	}
+	return local
+}
@@ -0,0 +1,84 @@
+package multicluster
+
+import (
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	k8sObject "github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/multicluster/object"
+	discovery "k8s.io/api/discovery/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+func TestEndpointSliceTopology(t *testing.T) {
+	zoneA, zoneB, node := "zone-a", "zone-b", "node-1"
+	ready := true
+	slice := &discovery.EndpointSlice{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "hdls1-slice1",
+			Namespace: "testns",
+			Labels:    map[string]string{mcs.LabelServiceName: "hdls1", mcs.LabelSourceCluster: "c1"},
+		},
+		Endpoints: []discovery.Endpoint{
+			{Addresses: []string{"10.0.0.1"}, Zone: &zoneA, NodeName: &node, Conditions: discovery.EndpointConditions{Ready: &ready}},
+			{
+				Addresses:  []string{"10.0.0.2"},
+				Zone:       &zoneA,
+				Hints:      &discovery.EndpointHints{ForZones: []discovery.ForZone{{Name: zoneB}}},
+				Conditions: discovery.EndpointConditions{Ready: &ready},
+			},
+			{Addresses: []string{"10.0.0.3"}, Conditions: discovery.EndpointConditions{Ready: &ready}},
+		},
+	}
+	obj, err := object.EndpointSliceToEndpoints(slice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep := obj.(*object.Endpoints).DeepCopyObject().(*object.Endpoints)
+
+	if len(ep.Topology) != 2 {
+		t.Fatalf("Expected topology of 2 endpoints, got %v", ep.Topology)
+	}
+	if top := ep.Topology["10.0.0.1"]; !top.InZone(zoneA) || top.InZone(zoneB) {
+		t.Errorf("Expected 10.0.0.1 to only serve %s, got %v", zoneA, top)
+	}
+	if top := ep.Topology["10.0.0.2"]; top.InZone(zoneA) || !top.InZone(zoneB) {
+		t.Errorf("Expected the hints of 10.0.0.2 to take precedence, got %v", top)
+	}
+	if ep.Subsets[0].Addresses[0].NodeName != node {
+		t.Errorf("Expected node name %s, got %q", node, ep.Subsets[0].Addresses[0].NodeName)
+	}
+}
+
+func TestZoneCandidates(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.addZoneRule(mustParseCIDR(t, "10.0.0.0/8"), "zone-a")
+	m.addZoneRule(mustParseCIDR(t, "10.1.0.0/16"), "zone-b")
+	m.addZoneRule(mustParseCIDR(t, "10.2.0.0/16"), "zone-c")
+
+	candidates := []candidate{
+		{cluster: "a", addr: k8sObject.EndpointAddress{IP: "172.0.0.1"}, topology: object.EndpointTopology{Zone: "zone-a"}, service: msg.Service{Host: "172.0.0.1"}},
+		{cluster: "b", addr: k8sObject.EndpointAddress{IP: "172.0.1.1"}, topology: object.EndpointTopology{Zone: "zone-a"}, service: msg.Service{Host: "172.0.1.1"}},
+		{cluster: "b", addr: k8sObject.EndpointAddress{IP: "172.0.1.2"}, topology: object.EndpointTopology{Zone: "zone-b"}, service: msg.Service{Host: "172.0.1.2"}},
+		{cluster: "b", addr: k8sObject.EndpointAddress{IP: "172.0.1.3"}, service: msg.Service{Host: "172.0.1.3"}},
+	}
+
+	tests := []struct {
+		client string
+		hosts  int
+	}{
+		{"10.3.0.1", 2},
+		{"10.1.0.1", 1},
+		// no endpoint in zone-c, fall back to all
+		{"10.2.0.1", 4},
+		// unknown zone
+		{"192.168.0.1", 4},
+	}
+	for i, tc := range tests {
+		if selected := m.zoneCandidates(net.ParseIP(tc.client), candidates); len(selected) != tc.hosts {
+			t.Errorf("Test %d: expected %d candidates, got %v", i, tc.hosts, selected)
+		}
+	}
+}
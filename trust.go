@@ -0,0 +1,144 @@
+package multicluster
+
+import (
+	"sync"
+
+	discovery "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const (
+	rejectReasonManagedBy = "managed_by"
+	rejectReasonCluster   = "cluster"
+)
+
+// sliceFilter rejects EndpointSlices not managed by an allowed controller, or from a cluster that
+// isn't allowed. An empty allowlist allows everything.
+type sliceFilter struct {
+	managedBy map[string]struct{}
+	clusters  map[string]struct{}
+	// rejected are the EndpointSlices currently rejected, so each is only logged and counted once.
+	rejected *rejectedSlices
+}
+
+// rejectedSlices is a set of EndpointSlices, by namespace/name.
+type rejectedSlices struct {
+	sync.Mutex
+	keys map[string]struct{}
+}
+
+func newRejectedSlices() *rejectedSlices { return &rejectedSlices{keys: map[string]struct{}{}} }
+
+// add adds key, returning true if it wasn't in the set. A nil set holds nothing.
+func (r *rejectedSlices) add(key string) bool {
+	if r == nil {
+		return true
+	}
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.keys[key]; ok {
+		return false
+	}
+	r.keys[key] = struct{}{}
+	return true
+}
+
+// remove removes key from the set.
+func (r *rejectedSlices) remove(key string) {
+	if r == nil {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	delete(r.keys, key)
+}
+
+// retain removes the keys that aren't in keys from the set.
+func (r *rejectedSlices) retain(keys map[string]struct{}) {
+	if r == nil {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	for key := range r.keys {
+		if _, ok := keys[key]; !ok {
+			delete(r.keys, key)
+		}
+	}
+}
+
+func sliceKey(es *discovery.EndpointSlice) string { return es.GetNamespace() + "/" + es.GetName() }
+
+// enabled returns true if f rejects any EndpointSlices.
+func (f sliceFilter) enabled() bool { return len(f.managedBy) > 0 || len(f.clusters) > 0 }
+
+// reject returns the reason to reject es, or an empty string if es is allowed.
+func (f sliceFilter) reject(es *discovery.EndpointSlice) string {
+	if len(f.managedBy) > 0 {
+		if _, ok := f.managedBy[es.Labels[discovery.LabelManagedBy]]; !ok {
+			return rejectReasonManagedBy
+		}
+	}
+	if len(f.clusters) > 0 {
+		if _, ok := f.clusters[es.Labels[mcs.LabelSourceCluster]]; !ok {
+			return rejectReasonCluster
+		}
+	}
+	return ""
+}
+
+// allowed returns true if es is allowed, logging and counting it otherwise, the first time it's rejected.
+func (f sliceFilter) allowed(es *discovery.EndpointSlice) bool {
+	reason := f.reject(es)
+	if reason == "" {
+		f.rejected.remove(sliceKey(es))
+		return true
+	}
+	if !f.rejected.add(sliceKey(es)) {
+		return false
+	}
+	log.Warningf("Rejected EndpointSlice %s/%s (%s: managed by %q, cluster %q)", es.GetNamespace(), es.GetName(),
+		reason, es.Labels[discovery.LabelManagedBy], es.Labels[mcs.LabelSourceCluster])
+	rejectedCount.WithLabelValues(reason).Inc()
+	return false
+}
+
+// filterList removes the rejected EndpointSlices from list. Rejected slices missing from the list,
+// e.g. deleted while not watching, are forgotten.
+func (f sliceFilter) filterList(list *discovery.EndpointSliceList) {
+	rejected := map[string]struct{}{}
+	items := list.Items[:0]
+	for i := range list.Items {
+		if f.allowed(&list.Items[i]) {
+			items = append(items, list.Items[i])
+			continue
+		}
+		rejected[sliceKey(&list.Items[i])] = struct{}{}
+	}
+	list.Items = items
+	f.rejected.retain(rejected)
+}
+
+// filterWatch drops the events of rejected EndpointSlices. A slice that becomes rejected is
+// deleted, so it doesn't linger in the cache. Events other than additions and modifications, e.g.
+// bookmarks, are passed as is.
+func (f sliceFilter) filterWatch(w watch.Interface) watch.Interface {
+	return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+		es, ok := e.Object.(*discovery.EndpointSlice)
+		if !ok || (e.Type != watch.Added && e.Type != watch.Modified) {
+			if ok && e.Type == watch.Deleted {
+				f.rejected.remove(sliceKey(es))
+			}
+			return e, true
+		}
+		if f.allowed(es) {
+			return e, true
+		}
+		if e.Type == watch.Modified {
+			e.Type = watch.Deleted
+			return e, true
+		}
+		return e, false
+	})
+}
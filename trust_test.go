@@ -0,0 +1,153 @@
+package multicluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	discovery "k8s.io/api/discovery/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+func trustTestSlice(name, managedBy, cluster string) *discovery.EndpointSlice {
+	return &discovery.EndpointSlice{ObjectMeta: meta.ObjectMeta{
+		Name:      name,
+		Namespace: "testns",
+		Labels: map[string]string{
+			mcs.LabelServiceName:     "svc1",
+			mcs.LabelSourceCluster:   cluster,
+			discovery.LabelManagedBy: managedBy,
+		},
+	}}
+}
+
+func TestSliceFilterReject(t *testing.T) {
+	f := sliceFilter{
+		managedBy: map[string]struct{}{"mcs-controller": {}},
+		clusters:  map[string]struct{}{"c1": {}},
+	}
+	tests := []struct {
+		slice  *discovery.EndpointSlice
+		reason string
+	}{
+		{trustTestSlice("s1", "mcs-controller", "c1"), ""},
+		{trustTestSlice("s2", "tenant", "c1"), rejectReasonManagedBy},
+		{trustTestSlice("s3", "mcs-controller", "c2"), rejectReasonCluster},
+	}
+	for i, tc := range tests {
+		if reason := f.reject(tc.slice); reason != tc.reason {
+			t.Errorf("Test %d: expected reason %q, got %q", i, tc.reason, reason)
+		}
+	}
+
+	if (sliceFilter{}).enabled() || (sliceFilter{}).reject(trustTestSlice("s4", "", "")) != "" {
+		t.Error("Expected an empty filter to allow everything")
+	}
+}
+
+func TestSliceFilterListWatch(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		trustTestSlice("trusted", "mcs-controller", "c1"),
+		trustTestSlice("injected", "tenant", "c1"),
+	)
+	f := sliceFilter{managedBy: map[string]struct{}{"mcs-controller": {}}}
+	ctx := context.TODO()
+
+	obj, err := endpointSliceListFunc(ctx, client, "testns", f)(meta.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := obj.(*discovery.EndpointSliceList)
+	if len(list.Items) != 1 || list.Items[0].Name != "trusted" {
+		t.Fatalf("Expected only the trusted slice, got %v", list.Items)
+	}
+
+	w, err := endpointSliceWatchFunc(ctx, client, "testns", f)(meta.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	slices := client.DiscoveryV1().EndpointSlices("testns")
+	if _, err := slices.Create(ctx, trustTestSlice("injected2", "tenant", "c1"), meta.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// a trusted slice taken over by another controller is deleted
+	if _, err := slices.Update(ctx, trustTestSlice("trusted", "tenant", "c1"), meta.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-w.ResultChan():
+		es := e.Object.(*discovery.EndpointSlice)
+		if e.Type != watch.Deleted || es.Name != "trusted" {
+			t.Errorf("Expected the trusted slice to be deleted, got %s of %s", e.Type, es.Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a watch event")
+	}
+}
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+	m := &dto.Metric{}
+	if err := c.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestSliceFilterCountsOnce(t *testing.T) {
+	f := sliceFilter{managedBy: map[string]struct{}{"mcs-controller": {}}, rejected: newRejectedSlices()}
+	counter := rejectedCount.WithLabelValues(rejectReasonManagedBy)
+	before := counterValue(t, counter)
+
+	list := &discovery.EndpointSliceList{Items: []discovery.EndpointSlice{*trustTestSlice("injected", "tenant", "c1")}}
+	f.filterList(list)
+	if len(list.Items) != 0 {
+		t.Fatalf("Expected the injected slice to be rejected, got %v", list.Items)
+	}
+	// the events of a rejected slice aren't counted again, nor a relist
+	for i := 0; i < 3; i++ {
+		if f.allowed(trustTestSlice("injected", "tenant", "c1")) {
+			t.Fatal("Expected the injected slice to be rejected")
+		}
+	}
+	list = &discovery.EndpointSliceList{Items: []discovery.EndpointSlice{*trustTestSlice("injected", "tenant", "c1")}}
+	f.filterList(list)
+	if n := counterValue(t, counter) - before; n != 1 {
+		t.Errorf("Expected the slice to be counted once, got %v", n)
+	}
+
+	// once allowed, a slice is counted again when rejected
+	f.allowed(trustTestSlice("injected", "mcs-controller", "c1"))
+	f.allowed(trustTestSlice("injected", "tenant", "c1"))
+	if n := counterValue(t, counter) - before; n != 2 {
+		t.Errorf("Expected the slice to be counted twice, got %v", n)
+	}
+}
+
+func TestSliceFilterWatchBookmark(t *testing.T) {
+	f := sliceFilter{managedBy: map[string]struct{}{"mcs-controller": {}}, rejected: newRejectedSlices()}
+	fw := watch.NewFake()
+	w := f.filterWatch(fw)
+	defer w.Stop()
+
+	go fw.Action(watch.Bookmark, &discovery.EndpointSlice{ObjectMeta: meta.ObjectMeta{ResourceVersion: "10"}})
+	select {
+	case e := <-w.ResultChan():
+		if e.Type != watch.Bookmark {
+			t.Errorf("Expected the bookmark, got %s", e.Type)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the bookmark to be passed")
+	}
+	if len(f.rejected.keys) != 0 {
+		t.Errorf("Expected the bookmark not to be rejected, got %v", f.rejected.keys)
+	}
+}
@@ -0,0 +1,66 @@
+package multicluster
+
+import (
+	"context"
+
+	k8sObject "github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/multicluster/object"
+	api "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+const (
+	versionAuto     = "auto"
+	versionV1alpha1 = "v1alpha1"
+	versionV1beta1  = "v1beta1"
+)
+
+// serviceImportVersions are the supported ServiceImport versions, newest first.
+var serviceImportVersions = []string{versionV1beta1, versionV1alpha1}
+
+// detectServiceImportVersion returns the newest supported ServiceImport version served by the
+// API server. If none is found, v1alpha1 is assumed.
+func detectServiceImportVersion(d discovery.DiscoveryInterface) string {
+	for _, version := range serviceImportVersions {
+		resources, err := d.ServerResourcesForGroupVersion(mcs.GroupVersion.Group + "/" + version)
+		if err != nil {
+			continue
+		}
+		for _, r := range resources.APIResources {
+			if r.Name == "serviceimports" {
+				log.Infof("Using ServiceImport version %s", version)
+				return version
+			}
+		}
+	}
+	log.Warningf("No supported ServiceImport version found, assuming %s", versionV1alpha1)
+	return versionV1alpha1
+}
+
+// watchServiceImportDynamic watches the ServiceImports of the given version through the dynamic client.
+func (c *control) watchServiceImportDynamic(ctx context.Context, client dynamic.Interface, version string) {
+	gvr := schema.GroupVersionResource{Group: mcs.GroupVersion.Group, Version: version, Resource: "serviceimports"}
+	resource := client.Resource(gvr).Namespace(api.NamespaceAll)
+	c.svcImportLister, c.svcImportController = k8sObject.NewIndexerInformer(
+		&cache.ListWatch{
+			ListFunc: func(o meta.ListOptions) (runtime.Object, error) {
+				return resource.List(ctx, o)
+			},
+			WatchFunc: func(o meta.ListOptions) (watch.Interface, error) {
+				return resource.Watch(ctx, o)
+			},
+		},
+		&unstructured.Unstructured{},
+		cache.ResourceEventHandlerFuncs{AddFunc: c.Add, UpdateFunc: c.Update, DeleteFunc: c.Delete},
+		cache.Indexers{svcNameNamespaceIndex: svcNameNamespaceIndexFunc},
+		k8sObject.DefaultProcessor(object.UnstructuredToServiceImport, nil),
+	)
+}
@@ -0,0 +1,123 @@
+package multicluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/multicluster/object"
+	api "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
+)
+
+func TestUnstructuredToServiceImport(t *testing.T) {
+	ports := []interface{}{map[string]interface{}{"name": "http", "protocol": "TCP", "port": int64(80)}}
+	tests := []struct {
+		name string
+		obj  map[string]interface{}
+	}{
+		{"v1alpha1", map[string]interface{}{
+			"apiVersion": "multicluster.x-k8s.io/v1alpha1",
+			"spec":       map[string]interface{}{"type": "ClusterSetIP", "ips": []interface{}{"10.0.0.1"}, "ports": ports},
+			"status":     map[string]interface{}{"clusters": []interface{}{map[string]interface{}{"cluster": "c1"}}},
+		}},
+		{"v1beta1", map[string]interface{}{
+			"apiVersion": "multicluster.x-k8s.io/v1beta1",
+			"type":       "ClusterSetIP",
+			"ips":        []interface{}{"10.0.0.1"},
+			"ports":      ports,
+			"status":     map[string]interface{}{"clusters": []interface{}{map[string]interface{}{"cluster": "c1"}}},
+		}},
+	}
+	for _, tc := range tests {
+		u := &unstructured.Unstructured{Object: tc.obj}
+		u.SetKind("ServiceImport")
+		u.SetName("svc1")
+		u.SetNamespace("testns")
+		o, err := object.UnstructuredToServiceImport(u)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		si := o.(*object.ServiceImport)
+		if si.Index != "svc1.testns" || si.Type != mcs.ClusterSetIP || len(si.ClusterIPs) != 1 || si.ClusterIPs[0] != "10.0.0.1" {
+			t.Errorf("%s: unexpected ServiceImport %+v", tc.name, si)
+		}
+		if len(si.Ports) != 1 || si.Ports[0].Port != 80 || si.Ports[0].Protocol != api.ProtocolTCP {
+			t.Errorf("%s: unexpected ports %+v", tc.name, si.Ports)
+		}
+		if len(si.Clusters) != 1 || si.Clusters[0] != "c1" {
+			t.Errorf("%s: unexpected clusters %v", tc.name, si.Clusters)
+		}
+	}
+}
+
+func TestUnstructuredToServiceImportUnknownVersion(t *testing.T) {
+	for _, version := range []string{"", "multicluster.x-k8s.io/v2", "v1"} {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{"type": "ClusterSetIP", "ips": []interface{}{"10.0.0.1"}},
+		}}
+		u.SetAPIVersion(version)
+		u.SetKind("ServiceImport")
+		u.SetName("svc1")
+		u.SetNamespace("testns")
+		if _, err := object.UnstructuredToServiceImport(u); err == nil {
+			t.Errorf("Expected an error for version %q", version)
+		}
+	}
+}
+
+func TestDetectServiceImportVersion(t *testing.T) {
+	d := fake.NewSimpleClientset().Discovery().(*fakediscovery.FakeDiscovery)
+	if v := detectServiceImportVersion(d); v != versionV1alpha1 {
+		t.Errorf("Expected %s without any served version, got %s", versionV1alpha1, v)
+	}
+
+	d.Resources = []*meta.APIResourceList{
+		{GroupVersion: "multicluster.x-k8s.io/v1alpha1", APIResources: []meta.APIResource{{Name: "serviceimports"}}},
+	}
+	if v := detectServiceImportVersion(d); v != versionV1alpha1 {
+		t.Errorf("Expected %s, got %s", versionV1alpha1, v)
+	}
+
+	d.Resources = append(d.Resources,
+		&meta.APIResourceList{GroupVersion: "multicluster.x-k8s.io/v1beta1", APIResources: []meta.APIResource{{Name: "serviceimports"}}})
+	if v := detectServiceImportVersion(d); v != versionV1beta1 {
+		t.Errorf("Expected %s, got %s", versionV1beta1, v)
+	}
+}
+
+func TestControllerServiceImportV1beta1(t *testing.T) {
+	si := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "multicluster.x-k8s.io/v1beta1",
+		"kind":       "ServiceImport",
+		"metadata":   map[string]interface{}{"name": "svc1", "namespace": "testns"},
+		"type":       "ClusterSetIP",
+		"ips":        []interface{}{"10.0.0.1"},
+	}}
+	gvr := schema.GroupVersionResource{Group: "multicluster.x-k8s.io", Version: versionV1beta1, Resource: "serviceimports"}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "ServiceImportList"}, si)
+	k8sClient := fake.NewSimpleClientset(&api.Namespace{ObjectMeta: meta.ObjectMeta{Name: "testns"}})
+
+	ctl := newController(context.TODO(), k8sClient, nil, dynamicClient, controllerOpts{serviceImportVersion: versionV1beta1})
+	go ctl.Run()
+	defer ctl.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !ctl.HasSynced() || len(ctl.SvcIndex("svc1.testns")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the v1beta1 ServiceImport to be indexed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if svc := ctl.SvcIndex("svc1.testns")[0]; len(svc.ClusterIPs) != 1 || svc.ClusterIPs[0] != "10.0.0.1" {
+		t.Errorf("Unexpected ServiceImport %+v", svc)
+	}
+}
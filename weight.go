@@ -0,0 +1,92 @@
+package multicluster
+
+import (
+	"hash/fnv"
+	"net"
+	"sort"
+
+	"github.com/coredns/multicluster/object"
+)
+
+// weighCandidates keeps the endpoints of a single cluster, picked with a probability proportional
+// to its weight. The pick is a hash of the client address, so a client keeps getting the same
+// cluster for as long as the weights and the clusters with endpoints don't change. The weights
+// from the ServiceImport annotation take precedence over the configured ones; clusters without a
+// weight have weight 0. If no weights apply, or all are 0, all candidates are kept.
+func (m *MultiCluster) weighCandidates(svc *object.ServiceImport, client net.IP, candidates []candidate) []candidate {
+	weights := svc.Weights
+	if len(weights) == 0 {
+		weights = m.weights
+	}
+	if len(weights) == 0 {
+		return candidates
+	}
+
+	cluster := pickCluster(candidateClusters(candidates), func(c string) int { return weights[c] }, client, svc.Index)
+	if cluster == "" {
+		return candidates
+	}
+	return clusterCandidates(candidates, cluster)
+}
+
+// pickCluster picks one of clusters with a probability proportional to its weight, based on a hash
+// of the client address and key. It returns an empty string if no cluster has a positive weight.
+func pickCluster(clusters []string, weight func(string) int, client net.IP, key string) string {
+	var total uint32
+	for _, c := range clusters {
+		if w := weight(c); w > 0 {
+			total += uint32(w)
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+
+	pick := clientHash(client, key) % total
+	for _, c := range clusters {
+		w := weight(c)
+		if w <= 0 {
+			continue
+		}
+		if pick < uint32(w) {
+			return c
+		}
+		pick -= uint32(w)
+	}
+	return ""
+}
+
+// candidateClusters returns the sorted clusters of candidates. Endpoints come in no particular
+// order, sorting keeps picks stable.
+func candidateClusters(candidates []candidate) []string {
+	var clusters []string
+	seen := map[string]struct{}{}
+	for _, c := range candidates {
+		if _, ok := seen[c.cluster]; ok {
+			continue
+		}
+		seen[c.cluster] = struct{}{}
+		clusters = append(clusters, c.cluster)
+	}
+	sort.Strings(clusters)
+	return clusters
+}
+
+// clusterCandidates returns the candidates of cluster.
+func clusterCandidates(candidates []candidate, cluster string) []candidate {
+	selected := candidates[:0:0]
+	for _, c := range candidates {
+		if c.cluster == cluster {
+			selected = append(selected, c)
+		}
+	}
+	return selected
+}
+
+// clientHash returns a hash of the client address and key.
+func clientHash(client net.IP, key string) uint32 {
+	h := fnv.New32a()
+	h.Write(client)
+	h.Write([]byte(key))
+	return h.Sum32()
+}
@@ -0,0 +1,71 @@
+package multicluster
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/multicluster/object"
+)
+
+func weightCandidates() []candidate {
+	return []candidate{
+		{cluster: "a", service: msg.Service{Host: "10.0.0.1"}},
+		{cluster: "b", service: msg.Service{Host: "10.0.1.1"}},
+		{cluster: "a", service: msg.Service{Host: "10.0.0.2"}},
+		{cluster: "c", service: msg.Service{Host: "10.0.2.1"}},
+	}
+}
+
+func TestWeighCandidates(t *testing.T) {
+	m := New([]string{"cluster.local."})
+	m.weights = map[string]int{"a": 90, "b": 10}
+	svc := &object.ServiceImport{Index: "hdls1.testns"}
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		client := net.ParseIP(fmt.Sprintf("10.%d.%d.1", i/256, i%256))
+		selected := m.weighCandidates(svc, client, weightCandidates())
+		if len(selected) == 0 {
+			t.Fatal("Expected candidates to be selected")
+		}
+		for _, c := range selected[1:] {
+			if c.cluster != selected[0].cluster {
+				t.Fatalf("Expected endpoints of a single cluster, got %v", selected)
+			}
+		}
+		counts[selected[0].cluster]++
+
+		// the same client gets the same cluster
+		again := m.weighCandidates(svc, client, weightCandidates())
+		if again[0].cluster != selected[0].cluster {
+			t.Fatalf("Expected stable selection for %s, got %s and %s", client, selected[0].cluster, again[0].cluster)
+		}
+	}
+	if counts["c"] != 0 {
+		t.Errorf("Expected cluster without weight to never be selected, got %d", counts["c"])
+	}
+	if counts["a"] < 800 || counts["b"] < 50 {
+		t.Errorf("Expected about 90%%/10%% split, got %v", counts)
+	}
+
+	// weights of the ServiceImport take precedence
+	svc.Weights = object.ParseWeights("c=1")
+	if selected := m.weighCandidates(svc, net.ParseIP("10.0.0.1"), weightCandidates()); len(selected) != 1 || selected[0].cluster != "c" {
+		t.Errorf("Expected cluster c to be selected, got %v", selected)
+	}
+
+	// all weights zero keeps all candidates
+	svc.Weights = map[string]int{"a": 0}
+	if selected := m.weighCandidates(svc, net.ParseIP("10.0.0.1"), weightCandidates()); len(selected) != 4 {
+		t.Errorf("Expected all candidates, got %v", selected)
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	w := object.ParseWeights(" a=90, b = 10 ,bogus,c=-1,d=x,=5")
+	if len(w) != 2 || w["a"] != 90 || w["b"] != 10 {
+		t.Errorf("Expected a=90 and b=10, got %v", w)
+	}
+}